@@ -0,0 +1,58 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// SourceVerifiedCondition indicates whether the fetched content has been
+// verified against the digest, checksum or signature configured in spec.verify.
+const SourceVerifiedCondition = "SourceVerified"
+
+const (
+	// FetchFailedReason signals that the content of the URL could not be fetched.
+	FetchFailedReason = "FetchFailed"
+
+	// AuthenticationFailedReason signals that the credentials or TLS configuration
+	// referenced by the object could not be loaded.
+	AuthenticationFailedReason = "AuthenticationFailed"
+
+	// ArchiveFailedReason signals that the fetched content could not be archived.
+	ArchiveFailedReason = "ArchiveFailed"
+
+	// VerificationFailedReason signals that the fetched content did not match
+	// the expected digest or checksum.
+	VerificationFailedReason = "VerificationFailed"
+
+	// ExtractionFailedReason signals that the fetched archive could not be extracted.
+	ExtractionFailedReason = "ExtractionFailed"
+
+	// SizeLimitExceededReason signals that the fetched content exceeds a size limit.
+	SizeLimitExceededReason = "SizeLimitExceeded"
+
+	// StorageFailedReason signals that the storage or the Artifact could not be reconciled.
+	StorageFailedReason = "StorageFailed"
+)
+
+const (
+	// FetchSucceededReason signals that a new revision has been fetched.
+	FetchSucceededReason = "FetchSucceeded"
+
+	// VerificationSucceededReason signals that a new revision has been verified.
+	VerificationSucceededReason = "VerificationSucceeded"
+
+	// NewArtifactReason signals that a new revision has been archived and
+	// published as Artifact.
+	NewArtifactReason = "NewArtifact"
+)
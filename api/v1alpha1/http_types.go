@@ -17,16 +17,253 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"time"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// HttpFinalizer is the finalizer added to Http objects to clean up their
+// Artifact and stored files on deletion.
+const HttpFinalizer = "finalizers.openfluxcd.openfluxcd"
+
+const (
+	// ArchiveMode extracts the fetched content into the Artifact.
+	ArchiveMode = "Archive"
+
+	// FileMode publishes the fetched content as a single file in the Artifact.
+	FileMode = "File"
+)
+
 // HttpSpec defines the desired state of Http
 type HttpSpec struct {
 	// URL defines where to get the archive from.
-	// Expects the content to be tar.gz.
 	URL string `json:"url"`
+
+	// Mirrors are further locations of the content of the URL, which are
+	// tried in order when the URL fails. They must serve the same content as
+	// the URL, which is enforced when a digest is pinned in Verify.
+	// +optional
+	Mirrors []HttpMirror `json:"mirrors,omitempty"`
+
+	// Mode defines how the content behind the URL is published. Archive extracts
+	// the content into the Artifact, File publishes the content as a single file.
+	// +kubebuilder:validation:Enum=Archive;File
+	// +kubebuilder:default=Archive
+	// +optional
+	Mode string `json:"mode,omitempty"`
+
+	// Filename is the name of the file in the Artifact when Mode is File.
+	// Defaults to the last element of the URL path.
+	// +kubebuilder:validation:Pattern="^[^/]+$"
+	// +kubebuilder:validation:XValidation:rule="self != '.' && self != '..'",message="filename must not be '.' or '..'"
+	// +optional
+	Filename string `json:"filename,omitempty"`
+
+	// Format is the archive format of the content behind the URL.
+	// When omitted, the format is detected from the content or its Content-Type.
+	// +kubebuilder:validation:Enum="tar.gz";"tar";"tar.xz";"tar.zst";"tar.bz2";"zip"
+	// +optional
+	Format string `json:"format,omitempty"`
+
+	// StripComponents is the number of leading path elements removed from the
+	// files of the extracted archive, such as the top-level directory of
+	// source tarballs. Files with fewer path elements are skipped.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	StripComponents int `json:"stripComponents,omitempty"`
+
+	// Path is the directory of the extracted archive, after StripComponents,
+	// which is published as the root of the Artifact. It must exist in the archive.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Ignore excludes the files matching the patterns in the .sourceignore
+	// format (which is the same as .gitignore) from the Artifact.
+	// +optional
+	Ignore *string `json:"ignore,omitempty"`
+
+	// Interval at which the URL is checked for new content.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +kubebuilder:default="10m"
+	// +optional
+	Interval metav1.Duration `json:"interval"`
+
+	// RetryInterval is the interval at which to retry a failed fetch.
+	// Defaults to Interval when omitted.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +optional
+	RetryInterval *metav1.Duration `json:"retryInterval,omitempty"`
+
+	// Timeout is the maximum time of fetching the URL, including retries and the
+	// transfer of the content. It covers all fetches of a reconciliation together,
+	// i.e. the checksum and signature files and the mirrors tried after the URL.
+	// Defaults to the timeout of the controller.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="timeout must be positive"
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Suspend tells the controller to stop fetching the URL, while the last
+	// Artifact is still served.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// MaxDownloadSize is the maximum size of the fetched content. It can only
+	// lower the limit of the controller, which is used when omitted or zero.
+	// +optional
+	MaxDownloadSize *resource.Quantity `json:"maxDownloadSize,omitempty"`
+
+	// MaxExtractedSize is the maximum total size of the files extracted from
+	// the fetched archive. It can only lower the limit of the controller, which
+	// is used when omitted or zero.
+	// +optional
+	MaxExtractedSize *resource.Quantity `json:"maxExtractedSize,omitempty"`
+
+	// MaxFileCount is the maximum number of files and directories extracted
+	// from the fetched archive. It can only lower the limit of the controller,
+	// which is used when omitted.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxFileCount *int64 `json:"maxFileCount,omitempty"`
+
+	// Retry configures how requests are retried within a reconciliation on
+	// transient failures. Omitted fields default to the controller flags.
+	// +optional
+	Retry *HttpRetry `json:"retry,omitempty"`
+
+	// SecretRef specifies the Secret in the same namespace containing the
+	// credentials for the URL. The Secret must contain either 'username' and
+	// 'password' for basic authentication or 'bearerToken' for token authentication.
+	// +optional
+	SecretRef *meta.LocalObjectReference `json:"secretRef,omitempty"`
+
+	// Headers are added to the requests of the URL and its mirrors, such as
+	// 'Accept: application/octet-stream' for GitHub release assets.
+	// +optional
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// HeadersSecretRef specifies the Secret in the same namespace whose keys
+	// and values are added as headers to the requests of the URL, such as
+	// 'PRIVATE-TOKEN' for GitLab. Like the credentials of SecretRef, they're
+	// not sent to mirrors and take precedence over Headers.
+	// +optional
+	HeadersSecretRef *meta.LocalObjectReference `json:"headersSecretRef,omitempty"`
+
+	// CertSecretRef specifies the Secret in the same namespace containing the
+	// TLS configuration for the URL and its mirrors. The Secret may contain
+	// 'ca.crt' to verify the server certificate and 'tls.crt' and 'tls.key' for
+	// client certificate authentication. Unlike the credentials of SecretRef,
+	// the client certificate is presented to mirrors as well.
+	// +optional
+	CertSecretRef *meta.LocalObjectReference `json:"certSecretRef,omitempty"`
+
+	// ProxySecretRef specifies the Secret in the same namespace containing the
+	// proxy the URL and its mirrors are fetched through. The Secret must contain
+	// 'address', e.g. 'http://proxy:3128', and may contain 'username' and
+	// 'password'. The proxy environment variables of the controller apply when omitted.
+	// +optional
+	ProxySecretRef *meta.LocalObjectReference `json:"proxySecretRef,omitempty"`
+
+	// NoProxy lists the hosts which are connected to directly instead of
+	// through the proxy of ProxySecretRef, in the format of the NO_PROXY
+	// environment variable, e.g. 'internal.example.com', '.svc.cluster.local'
+	// or '10.0.0.0/8'. Requires ProxySecretRef; without it the NO_PROXY
+	// environment variable of the controller applies.
+	// +optional
+	NoProxy []string `json:"noProxy,omitempty"`
+
+	// MinTLSVersion is the minimum TLS version accepted when connecting to the URL.
+	// +kubebuilder:validation:Enum="1.0";"1.1";"1.2";"1.3"
+	// +optional
+	MinTLSVersion string `json:"minTLSVersion,omitempty"`
+
+	// Verify verifies the fetched content before it's published.
+	// +optional
+	Verify *HttpVerification `json:"verify,omitempty"`
+}
+
+// HttpMirror defines a further location of the content of the URL.
+type HttpMirror struct {
+	// URL of the mirror.
+	URL string `json:"url"`
+
+	// SecretRef specifies the Secret in the same namespace containing the
+	// credentials for the mirror, in the same format as the SecretRef of the
+	// URL. The credentials of the URL aren't sent to mirrors.
+	// +optional
+	SecretRef *meta.LocalObjectReference `json:"secretRef,omitempty"`
+}
+
+// HttpVerification defines how the fetched content is verified.
+// Content which doesn't match is never published.
+type HttpVerification struct {
+	// Digest is the expected digest of the fetched content in the form
+	// '<algorithm>:<hex>', e.g. 'sha256:...' or 'sha512:...'.
+	// +kubebuilder:validation:Pattern="^(sha256|sha384|sha512):[a-f0-9]+$"
+	// +optional
+	Digest string `json:"digest,omitempty"`
+
+	// ChecksumURL is the URL of a checksum file in the format of sha256sum,
+	// e.g. SHA256SUMS. The checksum is looked up by the file name of the URL.
+	// The TLS configuration of the URL applies to it as well, the credentials of
+	// the URL only if it has the same scheme and host. The same goes for
+	// SignatureURL and BundleURL.
+	// +optional
+	ChecksumURL string `json:"checksumURL,omitempty"`
+
+	// Provider is the signature provider used to verify the fetched content.
+	// +kubebuilder:validation:Enum=cosign
+	// +optional
+	Provider string `json:"provider,omitempty"`
+
+	// SecretRef specifies the Secret in the same namespace containing the
+	// PEM encoded public keys of the provider. Every key ending with '.pub' is
+	// trusted.
+	// +optional
+	SecretRef *meta.LocalObjectReference `json:"secretRef,omitempty"`
+
+	// SignatureURL is the URL of the base64 encoded detached signature of the
+	// fetched content, as produced by 'cosign sign-blob'.
+	// +optional
+	SignatureURL string `json:"signatureURL,omitempty"`
+
+	// BundleURL is the URL of the cosign or Sigstore bundle containing the
+	// signature of the fetched content. It's mutually exclusive with SignatureURL.
+	// +optional
+	BundleURL string `json:"bundleURL,omitempty"`
 }
 
+// HttpRetry defines the retry policy of the requests for an Http object.
+type HttpRetry struct {
+	// MaxAttempts is the maximum number of attempts including the first one.
+	// A value of 1 disables retries.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10
+	// +optional
+	MaxAttempts *int `json:"maxAttempts,omitempty"`
+
+	// Backoff is the wait before the first retry, which doubles with every further retry.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +optional
+	Backoff *metav1.Duration `json:"backoff,omitempty"`
+
+	// MaxBackoff caps the wait between attempts, zero doesn't cap it. A Retry-After
+	// header asking for a longer wait ends the retries until the next reconciliation.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +optional
+	MaxBackoff *metav1.Duration `json:"maxBackoff,omitempty"`
+}
+
+// CosignProvider verifies signatures created by cosign.
+const CosignProvider = "cosign"
+
 // HttpStatus defines the observed state of Http
 type HttpStatus struct {
 	// ObservedGeneration is the last reconciled generation.
@@ -47,6 +284,22 @@ type HttpStatus struct {
 
 	// ArtifactName present what the name of the generated artifact is.
 	ArtifactName string `json:"artifactName,omitempty"`
+
+	// LastAppliedURL is the URL or mirror the last applied revision was fetched from.
+	// +optional
+	LastAppliedURL string `json:"lastAppliedURL,omitempty"`
+
+	// ETag is the entity tag of the content of the last applied revision.
+	// It's sent as If-None-Match to skip fetching unchanged content.
+	// +optional
+	ETag string `json:"etag,omitempty"`
+
+	// LastModified is the modification time of the content of the last applied
+	// revision. It's sent as If-Modified-Since to skip fetching unchanged content.
+	// +optional
+	LastModified string `json:"lastModified,omitempty"`
+
+	meta.ReconcileRequestStatus `json:",inline"`
 }
 
 // GetConditions returns the status conditions of the object.
@@ -59,6 +312,22 @@ func (in *Http) SetConditions(conditions []metav1.Condition) {
 	in.Status.Conditions = conditions
 }
 
+// GetRequeueAfter returns the duration after which the source must be
+// reconciled again.
+func (in Http) GetRequeueAfter() time.Duration {
+	return in.Spec.Interval.Duration
+}
+
+// GetRetryInterval returns the duration after which a failed reconciliation
+// is retried. It falls back to the interval if no retry interval is set.
+func (in Http) GetRetryInterval() time.Duration {
+	if in.Spec.RetryInterval != nil {
+		return in.Spec.RetryInterval.Duration
+	}
+
+	return in.GetRequeueAfter()
+}
+
 func (in *Http) GetObjectMeta() *metav1.ObjectMeta {
 	return &in.ObjectMeta
 }
@@ -69,6 +338,10 @@ func (in *Http) GetKind() string {
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description=""
+//+kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].message",description=""
+//+kubebuilder:printcolumn:name="Suspended",type="boolean",JSONPath=".spec.suspend",description=""
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description=""
 
 // Http is the Schema for the https API
 type Http struct {
@@ -17,8 +17,11 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"time"
@@ -27,10 +30,14 @@ import (
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"github.com/fluxcd/pkg/runtime/events"
+	"github.com/fluxcd/pkg/runtime/jitter"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
@@ -42,9 +49,12 @@ import (
 	openfluxcdv1alpha1 "github.com/openfluxcd/http-source-controller/api/v1alpha1"
 	"github.com/openfluxcd/http-source-controller/internal/controller"
 	"github.com/openfluxcd/http-source-controller/internal/fetcher"
+	"github.com/openfluxcd/http-source-controller/internal/tracing"
 	//+kubebuilder:scaffold:imports
 )
 
+const controllerName = "http-source-controller"
+
 var (
 	scheme                   = runtime.NewScheme()
 	setupLog                 = ctrl.Log.WithName("setup")
@@ -70,6 +80,13 @@ func main() {
 		storagePath          string
 		storageAddr          string
 		storageAdvAddr       string
+		intervalJitter       uint
+		eventsAddr           string
+		otlpEndpoint         string
+		retryPolicy          fetcher.RetryPolicy
+		limits               fetcher.Limits
+		fetchTimeout         time.Duration
+		connectTimeout       time.Duration
 	)
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
@@ -84,6 +101,29 @@ func main() {
 	flag.StringVar(&storageAddr, "storage-addr", ":9090", "The address the static file server binds to.")
 	flag.StringVar(&storageAdvAddr, "storage-adv-addr", "", "The advertised address of the static file server.")
 	flag.StringVar(&storagePath, "storage-path", "/data", "The local storage path.")
+	flag.StringVar(&eventsAddr, "events-addr", "",
+		"The address of the events receiver, e.g. the notification-controller, which is notified about revision changes and failures.")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "",
+		"The OTLP HTTP endpoint traces are exported to, e.g. http://otel-collector:4318. Tracing is disabled if empty.")
+	flag.IntVar(&retryPolicy.MaxAttempts, "fetch-max-attempts", 3,
+		"The maximum number of attempts of a request on transient failures, including the first one.")
+	flag.DurationVar(&retryPolicy.Backoff, "fetch-backoff", time.Second,
+		"The wait before the first retry of a request, which doubles with every further retry.")
+	flag.DurationVar(&retryPolicy.MaxBackoff, "fetch-max-backoff", 30*time.Second,
+		"The maximum wait between retries of a request, 0 doesn't cap it. Longer Retry-After headers end the retries.")
+	flag.DurationVar(&fetchTimeout, "default-fetch-timeout", fetcher.DefaultTimeout,
+		"The default timeout of all fetches of a reconciliation including retries, which is overridden by spec.timeout.")
+	flag.DurationVar(&connectTimeout, "fetch-connect-timeout", 15*time.Second,
+		"The timeout of establishing a connection including the TLS handshake.")
+	flag.Int64Var(&limits.MaxDownloadSize, "max-download-size", fetcher.DefaultMaxDownloadSize,
+		"The maximum size of fetched content in bytes. A negative value disables the limit.")
+	flag.Int64Var(&limits.MaxExtractedSize, "max-extracted-size", fetcher.DefaultMaxExtractedSize,
+		"The maximum total size of the files extracted from a fetched archive in bytes. A negative value disables the limit.")
+	flag.Int64Var(&limits.MaxFileCount, "max-file-count", fetcher.DefaultMaxFileCount,
+		"The maximum number of files and directories extracted from a fetched archive. A negative value disables the limit.")
+	flag.UintVar(&intervalJitter, "interval-jitter-percentage", 5,
+		"Percentage of jitter to apply to interval durations. A value of 10 "+
+			"will apply a jitter of +/-10% to the interval duration. It must be less than 100.")
 
 	opts := zap.Options{
 		Development: true,
@@ -94,6 +134,23 @@ func main() {
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 	ctx := ctrl.SetupSignalHandler()
 
+	if intervalJitter >= 100 {
+		setupLog.Error(fmt.Errorf("invalid interval jitter percentage: %d", intervalJitter), "unable to set interval jitter")
+		os.Exit(1)
+	}
+	jitter.SetGlobalIntervalJitter(float64(intervalJitter)/100.0, nil)
+
+	if fetchTimeout <= 0 || connectTimeout <= 0 {
+		setupLog.Error(fmt.Errorf("invalid fetch timeouts: default %s, connect %s", fetchTimeout, connectTimeout), "unable to set fetch timeouts")
+		os.Exit(1)
+	}
+
+	shutdownTracing, err := tracing.Setup(ctx, otlpEndpoint, controllerName)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
@@ -116,6 +173,12 @@ func main() {
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
+		Client: client.Options{
+			// Caching Secrets would keep every Secret of the cluster in memory.
+			Cache: &client.CacheOptions{
+				DisableFor: []client.Object{&corev1.Secret{}},
+			},
+		},
 		Metrics: metricsserver.Options{
 			BindAddress:   metricsAddr,
 			SecureServing: secureMetrics,
@@ -142,9 +205,18 @@ func main() {
 		os.Exit(1)
 	}
 
-	fetch := fetcher.NewFetcher(&http.Client{
-		Timeout: 15 * time.Second,
-	})
+	eventRecorder, err := events.NewRecorder(mgr, ctrl.Log, eventsAddr, controllerName)
+	if err != nil {
+		setupLog.Error(err, "unable to create event recorder")
+		os.Exit(1)
+	}
+
+	// The total time of a fetch is limited per object, only connecting is limited here.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+
+	fetch := fetcher.NewFetcher(&http.Client{Transport: transport})
 	storage, server, err := server.NewArtifactStore(mgr.GetClient(), mgr.GetScheme(), storagePath, storageAddr, storageAdvAddr, artifactRetentionTTL, artifactRetentionRecords)
 	if err != nil {
 		setupLog.Error(err, "unable to initialize storage")
@@ -152,10 +224,14 @@ func main() {
 	}
 
 	if err = (&controller.HttpReconciler{
-		Client:  mgr.GetClient(),
-		Scheme:  mgr.GetScheme(),
-		Fetcher: fetch,
-		Storage: storage,
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		Fetcher:       fetch,
+		Storage:       storage,
+		EventRecorder: eventRecorder,
+		RetryPolicy:   retryPolicy,
+		Limits:        limits,
+		FetchTimeout:  fetchTimeout,
 	}).SetupWithManager(ctx, mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Http")
 		os.Exit(1)
@@ -187,4 +263,12 @@ func main() {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+
+	// The signal context is done already, flush the remaining spans with a fresh one.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		setupLog.Error(err, "unable to flush traces")
+	}
 }
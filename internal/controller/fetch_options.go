@@ -0,0 +1,496 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	"golang.org/x/net/http/httpguts"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	openfluxcdv1alpha1 "github.com/openfluxcd/http-source-controller/api/v1alpha1"
+	"github.com/openfluxcd/http-source-controller/internal/fetcher"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// fetchOptions collects the options for fetching the URL of the object, which
+// apply to its mirrors as well.
+func (r *HttpReconciler) fetchOptions(ctx context.Context, obj *openfluxcdv1alpha1.Http) ([]fetcher.FetchOptionsFn, error) {
+	credentialOpts, err := r.credentialOptions(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := r.transportOptions(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(obj.Spec.Headers) > 0 {
+		if err := validateHeaders(obj.Spec.Headers); err != nil {
+			return nil, newStalledError(openfluxcdv1alpha1.FetchFailedReason, fmt.Errorf("invalid spec.headers: %w", err))
+		}
+
+		opts = append(opts, fetcher.WithHeaders(obj.Spec.Headers))
+	}
+
+	limits, err := r.limits(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	// The deadline of the reconciliation applies first, but the Fetcher would apply its default timeout otherwise.
+	opts = append(opts,
+		fetcher.WithRetryPolicy(r.retryPolicy(obj)),
+		fetcher.WithLimits(limits),
+		fetcher.WithTimeout(r.timeout(obj)),
+	)
+
+	// Checksum and signature files are only fetched once the URL responds with content.
+	if obj.Spec.Verify != nil {
+		opts = append(opts, fetcher.WithVerifyOptions(r.verifyOptionsFunc(obj, slices.Clone(opts), credentialOpts)))
+	}
+
+	if obj.Spec.Format != "" {
+		opts = append(opts, fetcher.WithFormat(fetcher.Format(obj.Spec.Format)))
+	}
+
+	if obj.Spec.StripComponents > 0 {
+		opts = append(opts, fetcher.WithStripComponents(obj.Spec.StripComponents))
+	}
+
+	if obj.Spec.Path != "" {
+		opts = append(opts, fetcher.WithPath(obj.Spec.Path))
+	}
+
+	if obj.Spec.Mode == openfluxcdv1alpha1.FileMode {
+		filename, err := fileName(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, fetcher.WithFile(filename))
+	}
+
+	return opts, nil
+}
+
+// retryPolicy overrides the default retry policy of the controller with the
+// fields set in the spec of the object.
+func (r *HttpReconciler) retryPolicy(obj *openfluxcdv1alpha1.Http) fetcher.RetryPolicy {
+	policy := r.RetryPolicy
+	if obj.Spec.Retry == nil {
+		return policy
+	}
+
+	if obj.Spec.Retry.MaxAttempts != nil {
+		policy.MaxAttempts = *obj.Spec.Retry.MaxAttempts
+	}
+
+	if obj.Spec.Retry.Backoff != nil {
+		policy.Backoff = obj.Spec.Retry.Backoff.Duration
+	}
+
+	if obj.Spec.Retry.MaxBackoff != nil {
+		policy.MaxBackoff = obj.Spec.Retry.MaxBackoff.Duration
+	}
+
+	return policy
+}
+
+// timeout returns a positive spec.timeout or the default timeout of the controller,
+// falling back to the default timeout of the Fetcher.
+func (r *HttpReconciler) timeout(obj *openfluxcdv1alpha1.Http) time.Duration {
+	if obj.Spec.Timeout != nil && obj.Spec.Timeout.Duration > 0 {
+		return obj.Spec.Timeout.Duration
+	}
+
+	if r.FetchTimeout > 0 {
+		return r.FetchTimeout
+	}
+
+	return fetcher.DefaultTimeout
+}
+
+// limits lowers the limits of the controller to the ones set in the spec of
+// the object. Objects can't raise or disable the limits of the controller.
+func (r *HttpReconciler) limits(obj *openfluxcdv1alpha1.Http) (fetcher.Limits, error) {
+	var limits fetcher.Limits
+	if obj.Spec.MaxDownloadSize != nil {
+		limits.MaxDownloadSize = obj.Spec.MaxDownloadSize.Value()
+	}
+
+	if obj.Spec.MaxExtractedSize != nil {
+		limits.MaxExtractedSize = obj.Spec.MaxExtractedSize.Value()
+	}
+
+	if obj.Spec.MaxFileCount != nil {
+		limits.MaxFileCount = *obj.Spec.MaxFileCount
+	}
+
+	if limits.MaxDownloadSize < 0 || limits.MaxExtractedSize < 0 || limits.MaxFileCount < 0 {
+		return fetcher.Limits{}, newStalledError(openfluxcdv1alpha1.FetchFailedReason,
+			errors.New("spec.maxDownloadSize, spec.maxExtractedSize and spec.maxFileCount must not be negative"))
+	}
+
+	return r.Limits.Narrow(limits), nil
+}
+
+// locations returns the URL and the mirrors of the object in the order they're
+// tried, each with its own credentials and the validators of the last applied
+// revision if it was fetched from there.
+func (r *HttpReconciler) locations(ctx context.Context, obj *openfluxcdv1alpha1.Http) ([]fetcher.Location, error) {
+	mirrors := append([]openfluxcdv1alpha1.HttpMirror{{URL: obj.Spec.URL, SecretRef: obj.Spec.SecretRef}}, obj.Spec.Mirrors...)
+
+	appliedURL := obj.Status.LastAppliedURL
+	if appliedURL == "" {
+		appliedURL = obj.Spec.URL
+	}
+
+	locations := make([]fetcher.Location, 0, len(mirrors))
+	for i, mirror := range mirrors {
+		var (
+			opts []fetcher.FetchOptionsFn
+			err  error
+		)
+
+		if i == 0 {
+			opts, err = r.credentialOptions(ctx, obj)
+		} else {
+			opts, err = r.authOptions(ctx, obj.Namespace, mirror.SecretRef)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if mirror.URL == appliedURL {
+			opts = append(opts, r.conditionalOptions(obj)...)
+		}
+
+		locations = append(locations, fetcher.Location{URL: mirror.URL, Options: opts})
+	}
+
+	return locations, nil
+}
+
+// credentialOptions returns the credentials and the headers from
+// spec.headersSecretRef, which are only sent to the URL.
+func (r *HttpReconciler) credentialOptions(ctx context.Context, obj *openfluxcdv1alpha1.Http) ([]fetcher.FetchOptionsFn, error) {
+	opts, err := r.authOptions(ctx, obj.Namespace, obj.Spec.SecretRef)
+	if err != nil {
+		return nil, err
+	}
+
+	if obj.Spec.HeadersSecretRef == nil {
+		return opts, nil
+	}
+
+	secret, err := r.getSecret(ctx, obj.Namespace, obj.Spec.HeadersSecretRef.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string, len(secret.Data))
+	for name, value := range secret.Data {
+		headers[name] = string(value)
+	}
+
+	// The error never contains the values of the headers.
+	if err := validateHeaders(headers); err != nil {
+		return nil, newStalledError(openfluxcdv1alpha1.AuthenticationFailedReason,
+			fmt.Errorf("invalid headers in secret '%s': %w", secret.Name, err))
+	}
+
+	return append(opts, fetcher.WithSecretHeaders(headers)), nil
+}
+
+// validateHeaders checks the names and values of the headers. Only the names
+// are reported, since the values may be secret.
+func validateHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return fmt.Errorf("invalid header name '%s'", name)
+		}
+
+		if !httpguts.ValidHeaderFieldValue(value) {
+			return fmt.Errorf("invalid value of header '%s'", name)
+		}
+	}
+
+	return nil
+}
+
+// authOptions reads the credentials from the referenced Secret and turns them
+// into fetch options.
+func (r *HttpReconciler) authOptions(ctx context.Context, namespace string, secretRef *meta.LocalObjectReference) ([]fetcher.FetchOptionsFn, error) {
+	if secretRef == nil {
+		return nil, nil
+	}
+
+	secret, err := r.getSecret(ctx, namespace, secretRef.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if token, ok := secret.Data["bearerToken"]; ok {
+		return []fetcher.FetchOptionsFn{fetcher.WithToken(string(token))}, nil
+	}
+
+	username, hasUsername := secret.Data["username"]
+	password, hasPassword := secret.Data["password"]
+	if !hasUsername || !hasPassword {
+		return nil, newStalledError(openfluxcdv1alpha1.AuthenticationFailedReason,
+			fmt.Errorf("secret '%s' must contain either 'username' and 'password' or 'bearerToken'", secret.Name))
+	}
+
+	return []fetcher.FetchOptionsFn{
+		fetcher.WithUsername(string(username)),
+		fetcher.WithPassword(string(password)),
+	}, nil
+}
+
+// transportOptions builds the TLS and proxy configuration of the object. The
+// dedicated transport for the configuration is cached by the Fetcher until the
+// referenced Secrets or the settings change, or the configuration is removed.
+func (r *HttpReconciler) transportOptions(ctx context.Context, obj *openfluxcdv1alpha1.Http) ([]fetcher.FetchOptionsFn, error) {
+	tlsOpt, tlsVersion, err := r.tlsOption(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	proxyOpt, proxyVersion, err := r.proxyOption(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []fetcher.FetchOptionsFn
+	for _, opt := range []fetcher.FetchOptionsFn{tlsOpt, proxyOpt} {
+		if opt != nil {
+			opts = append(opts, opt)
+		}
+	}
+
+	key := client.ObjectKeyFromObject(obj).String()
+	if len(opts) == 0 {
+		// The key alone lets the Fetcher forget the transport of a removed configuration.
+		return []fetcher.FetchOptionsFn{fetcher.WithTransportCacheKey(key, "")}, nil
+	}
+
+	version := "tls:" + tlsVersion + ";proxy:" + proxyVersion
+
+	return append(opts, fetcher.WithTransportCacheKey(key, version)), nil
+}
+
+// tlsOption builds the TLS configuration from the Secret referenced by the
+// object and its minimum TLS version, together with the version of the
+// configuration.
+func (r *HttpReconciler) tlsOption(ctx context.Context, obj *openfluxcdv1alpha1.Http) (fetcher.FetchOptionsFn, string, error) {
+	if obj.Spec.CertSecretRef == nil && obj.Spec.MinTLSVersion == "" {
+		return nil, "", nil
+	}
+
+	config := &tls.Config{}
+	version := obj.Spec.MinTLSVersion
+
+	if obj.Spec.MinTLSVersion != "" {
+		minVersion, ok := tlsVersions[obj.Spec.MinTLSVersion]
+		if !ok {
+			return nil, "", newStalledError(openfluxcdv1alpha1.AuthenticationFailedReason,
+				fmt.Errorf("unsupported minimum TLS version '%s'", obj.Spec.MinTLSVersion))
+		}
+
+		config.MinVersion = minVersion
+	}
+
+	if obj.Spec.CertSecretRef != nil {
+		secret, err := r.getSecret(ctx, obj.Namespace, obj.Spec.CertSecretRef.Name)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if err := configureTLSFromSecret(config, secret); err != nil {
+			return nil, "", newStalledError(openfluxcdv1alpha1.AuthenticationFailedReason,
+				fmt.Errorf("invalid TLS configuration in secret '%s': %w", secret.Name, err))
+		}
+
+		version += "/" + secret.ResourceVersion
+	}
+
+	return fetcher.WithTLSConfig(config), version, nil
+}
+
+// proxyOption builds the proxy configuration from the Secret referenced by
+// spec.proxySecretRef and spec.noProxy, together with the version of the
+// configuration. Without a Secret, the proxy environment variables apply.
+func (r *HttpReconciler) proxyOption(ctx context.Context, obj *openfluxcdv1alpha1.Http) (fetcher.FetchOptionsFn, string, error) {
+	if obj.Spec.ProxySecretRef == nil {
+		if len(obj.Spec.NoProxy) > 0 {
+			return nil, "", newStalledError(openfluxcdv1alpha1.FetchFailedReason,
+				errors.New("spec.noProxy requires spec.proxySecretRef, the NO_PROXY environment variable applies otherwise"))
+		}
+
+		return nil, "", nil
+	}
+
+	secret, err := r.getSecret(ctx, obj.Namespace, obj.Spec.ProxySecretRef.Name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	proxyURL, err := proxyURLFromSecret(secret)
+	if err != nil {
+		return nil, "", newStalledError(openfluxcdv1alpha1.AuthenticationFailedReason,
+			fmt.Errorf("invalid proxy configuration in secret '%s': %w", secret.Name, err))
+	}
+
+	noProxy := strings.Join(obj.Spec.NoProxy, ",")
+
+	return fetcher.WithProxy(proxyURL, noProxy), secret.ResourceVersion + "/" + noProxy, nil
+}
+
+// proxyURLFromSecret returns the URL of the proxy at 'address' with the
+// optional 'username' and 'password' of the Secret.
+func proxyURLFromSecret(secret *corev1.Secret) (*url.URL, error) {
+	address, ok := secret.Data["address"]
+	if !ok {
+		return nil, errors.New("no 'address' found")
+	}
+
+	proxyURL, err := url.Parse(string(address))
+	if err != nil {
+		// The address may contain credentials, so it's not part of the error.
+		return nil, errors.New("failed to parse 'address'")
+	}
+
+	if proxyURL.Scheme == "" || proxyURL.Host == "" {
+		return nil, errors.New("'address' must be an absolute URL such as 'http://proxy:3128'")
+	}
+
+	username, hasUsername := secret.Data["username"]
+	password, hasPassword := secret.Data["password"]
+	if hasUsername != hasPassword {
+		return nil, errors.New("expected both or neither of 'username' and 'password'")
+	}
+
+	if hasUsername {
+		proxyURL.User = url.UserPassword(string(username), string(password))
+	}
+
+	return proxyURL, nil
+}
+
+// configureTLSFromSecret adds the CA bundle and client certificate of the Secret to the config.
+func configureTLSFromSecret(config *tls.Config, secret *corev1.Secret) error {
+	certPEM, hasCert := secret.Data[corev1.TLSCertKey]
+	keyPEM, hasKey := secret.Data[corev1.TLSPrivateKeyKey]
+	caPEM, hasCA := secret.Data["ca.crt"]
+
+	if hasCert != hasKey {
+		return fmt.Errorf("expected both or neither of '%s' and '%s'", corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+	}
+
+	if !hasCert && !hasCA {
+		return fmt.Errorf("no '%s' and '%s', or 'ca.crt' found", corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+	}
+
+	if hasCert {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return fmt.Errorf("failed to parse client certificate: %w", err)
+		}
+
+		config.Certificates = append(config.Certificates, cert)
+	}
+
+	if hasCA {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			return fmt.Errorf("failed to load system cert pool: %w", err)
+		}
+
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return fmt.Errorf("failed to parse CA certificate")
+		}
+
+		config.RootCAs = pool
+	}
+
+	return nil
+}
+
+// fileName returns the name of the published file in File mode.
+func fileName(obj *openfluxcdv1alpha1.Http) (string, error) {
+	if obj.Spec.Filename != "" {
+		if !isFileName(obj.Spec.Filename) {
+			return "", newStalledError(openfluxcdv1alpha1.FetchFailedReason, fmt.Errorf("invalid spec.filename '%s'", obj.Spec.Filename))
+		}
+
+		return obj.Spec.Filename, nil
+	}
+
+	return urlFileName(obj.Spec.URL)
+}
+
+// urlFileName returns the last element of the path of rawURL.
+func urlFileName(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", newStalledError(meta.InvalidURLReason, fmt.Errorf("failed to parse url '%s': %w", rawURL, err))
+	}
+
+	name := path.Base(u.Path)
+	if !isFileName(name) {
+		return "", newStalledError(meta.InvalidURLReason, fmt.Errorf("url '%s' has no file name, set spec.filename", rawURL))
+	}
+
+	return name, nil
+}
+
+// isFileName reports whether name names a file in a directory, i.e. it's
+// neither '.' nor '..' and contains no separator.
+func isFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.Contains(name, "/")
+}
+
+func (r *HttpReconciler) getSecret(ctx context.Context, namespace, name string) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, secret); err != nil {
+		return nil, newReconcileError(openfluxcdv1alpha1.AuthenticationFailedReason,
+			fmt.Errorf("failed to get secret '%s/%s': %w", namespace, name, err))
+	}
+
+	return secret, nil
+}
@@ -18,23 +18,44 @@ package controller
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/runtime/conditions"
+	"github.com/fluxcd/pkg/runtime/jitter"
 	"github.com/fluxcd/pkg/runtime/patch"
+	"github.com/fluxcd/pkg/runtime/predicates"
+	"github.com/fluxcd/pkg/sourceignore"
 	artifactv1 "github.com/openfluxcd/artifact/api/v1alpha1"
 	"github.com/openfluxcd/controller-manager/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	kuberecorder "k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	openfluxcdv1alpha1 "github.com/openfluxcd/http-source-controller/api/v1alpha1"
 	"github.com/openfluxcd/http-source-controller/internal/fetcher"
+	"github.com/openfluxcd/http-source-controller/internal/metrics"
+	"github.com/openfluxcd/http-source-controller/internal/tracing"
 )
 
 // HttpReconciler reconciles a Http object
@@ -42,14 +63,26 @@ type HttpReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 
-	Fetcher *fetcher.Fetcher
-	Storage *storage.Storage
+	Fetcher       *fetcher.Fetcher
+	Storage       *storage.Storage
+	EventRecorder kuberecorder.EventRecorder
+
+	// RetryPolicy is the default retry policy of requests, which is overridden by spec.retry.
+	RetryPolicy fetcher.RetryPolicy
+
+	// Limits are the default limits of fetched content, which are overridden by the spec.
+	Limits fetcher.Limits
+
+	// FetchTimeout is the default timeout of fetching the URL, which is overridden by spec.timeout.
+	FetchTimeout time.Duration
 }
 
 //+kubebuilder:rbac:groups=openfluxcd.openfluxcd,resources=https,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=openfluxcd.openfluxcd,resources=https/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=openfluxcd.openfluxcd,resources=https/finalizers,verbs=update
 //+kubebuilder:rbac:groups=openfluxcd.mandelsoft.org,resources=artifacts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile loop.
 func (r *HttpReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, retErr error) {
@@ -57,6 +90,14 @@ func (r *HttpReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctr
 
 	logger.Info("starting http source controller loop")
 
+	ctx, span := tracing.Tracer().Start(ctx, "Reconcile", trace.WithAttributes(
+		attribute.String("namespace", req.Namespace),
+		attribute.String("name", req.Name),
+	))
+	defer func() {
+		tracing.End(span, retErr)
+	}()
+
 	obj := &openfluxcdv1alpha1.Http{}
 	if err := r.Client.Get(ctx, req.NamespacedName, obj); err != nil {
 		if apierrors.IsNotFound(err) {
@@ -66,24 +107,123 @@ func (r *HttpReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctr
 		return ctrl.Result{}, fmt.Errorf("failed to get component object: %w", err)
 	}
 
-	if obj.GetDeletionTimestamp() != nil {
-		logger.Info("deleting http source controller")
-		return ctrl.Result{}, nil
-	}
-
 	patchHelper := patch.NewSerialPatcher(obj, r.Client)
 
 	// Always attempt to patch the object and status after each reconciliation.
 	defer func() {
-		if perr := patchHelper.Patch(ctx, obj); perr != nil {
+		perr := patchHelper.Patch(ctx, obj)
+		if !obj.GetDeletionTimestamp().IsZero() {
+			// The object is gone once the finalizer has been removed.
+			perr = kerrors.FilterOut(perr, apierrors.IsNotFound)
+		}
+
+		if perr != nil {
 			retErr = errors.Join(retErr, perr)
 		}
 	}()
 
+	if !obj.GetDeletionTimestamp().IsZero() {
+		logger.Info("deleting http source")
+
+		return ctrl.Result{}, r.reconcileDelete(ctx, obj)
+	}
+
+	// Add the finalizer first, so the Artifact and the stored files are cleaned up on deletion.
+	controllerutil.AddFinalizer(obj, openfluxcdv1alpha1.HttpFinalizer)
+
+	if obj.Spec.Suspend {
+		logger.Info("reconciliation is suspended")
+		markSuspended(obj)
+
+		return ctrl.Result{}, nil
+	}
+
+	// Acknowledge the reconcile request once it has been handled, whatever the outcome.
+	defer func() {
+		if v, ok := meta.ReconcileAnnotationValue(obj.GetAnnotations()); ok {
+			obj.Status.SetLastHandledReconcileRequest(v)
+		}
+	}()
+
+	conditions.MarkReconciling(obj, meta.ProgressingReason, "reconciling http source")
+
+	if err := r.reconcile(ctx, obj); err != nil {
+		reason, stalled := reasonFor(err)
+		conditions.MarkFalse(obj, meta.ReadyCondition, reason, "%s", err)
+		r.EventRecorder.Event(obj, corev1.EventTypeWarning, reason, err.Error())
+
+		if reason == openfluxcdv1alpha1.VerificationFailedReason {
+			conditions.MarkFalse(obj, openfluxcdv1alpha1.SourceVerifiedCondition, reason, "%s", err)
+		}
+
+		if stalled {
+			// Retrying won't help, wait for a change to the object or its Secrets.
+			conditions.MarkStalled(obj, reason, "%s", err)
+			obj.Status.ObservedGeneration = obj.Generation
+			logger.Error(err, "reconciliation stalled")
+
+			return ctrl.Result{}, nil
+		}
+
+		conditions.MarkReconciling(obj, meta.ProgressingWithRetryReason, "retrying after failure: %s", err)
+
+		// Don't retry before the server is willing to answer again.
+		if wait := retryAfter(err); wait > obj.GetRetryInterval() {
+			logger.Error(err, "failed to reconcile http source", "retryAfter", wait)
+
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+
+		if obj.GetRetryInterval() == 0 {
+			return ctrl.Result{}, err
+		}
+
+		logger.Error(err, "failed to reconcile http source", "retryInterval", obj.GetRetryInterval())
+
+		return jitter.JitteredRequeueInterval(ctrl.Result{RequeueAfter: obj.GetRetryInterval()}), nil
+	}
+
+	r.recordRevisionTimestamp(ctx, obj)
+
+	conditions.Delete(obj, meta.ReconcilingCondition)
+	conditions.Delete(obj, meta.StalledCondition)
+	conditions.MarkTrue(obj, meta.ReadyCondition, meta.SucceededReason, "stored artifact for revision '%s'", obj.Status.LastAppliedRevision)
+	obj.Status.ObservedGeneration = obj.Generation
+
+	return jitter.JitteredRequeueInterval(ctrl.Result{RequeueAfter: obj.GetRequeueAfter()}), nil
+}
+
+// markSuspended reports the suspension in the Ready condition. The Artifact of
+// the last applied revision stays in place, so a ready object stays ready.
+func markSuspended(obj *openfluxcdv1alpha1.Http) {
+	conditions.Delete(obj, meta.ReconcilingCondition)
+	conditions.Delete(obj, meta.StalledCondition)
+
+	if obj.Status.LastAppliedRevision == "" {
+		conditions.MarkFalse(obj, meta.ReadyCondition, meta.SuspendedReason, "reconciliation is suspended")
+
+		return
+	}
+
+	if conditions.IsReady(obj) {
+		conditions.MarkTrue(obj, meta.ReadyCondition, meta.SuspendedReason,
+			"reconciliation is suspended, serving revision '%s'", obj.Status.LastAppliedRevision)
+
+		return
+	}
+
+	conditions.MarkFalse(obj, meta.ReadyCondition, meta.SuspendedReason,
+		"reconciliation is suspended, serving revision '%s'", obj.Status.LastAppliedRevision)
+}
+
+// reconcile fetches the content behind the URL and publishes it as an Artifact.
+// If neither the digest of the content nor the fields shaping the Artifact changed,
+// the existing Artifact is left untouched.
+func (r *HttpReconciler) reconcile(ctx context.Context, obj *openfluxcdv1alpha1.Http) error {
 	// Create temp working dir
 	tmpDir, err := os.MkdirTemp("", fmt.Sprintf("%s-%s-%s-", obj.Kind, obj.Namespace, obj.Name))
 	if err != nil {
-		return ctrl.Result{}, fmt.Errorf("failed to create temporary working directory: %w", err)
+		return newReconcileError(openfluxcdv1alpha1.StorageFailedReason, fmt.Errorf("failed to create temporary working directory: %w", err))
 	}
 	defer func() {
 		if err = os.RemoveAll(tmpDir); err != nil {
@@ -91,41 +231,323 @@ func (r *HttpReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctr
 		}
 	}()
 
+	// Only the content directory is archived, intermediate files are kept apart in the staging directory.
+	contentDir := filepath.Join(tmpDir, "content")
+	stagingDir := filepath.Join(tmpDir, "staging")
+	for _, dir := range []string{contentDir, stagingDir} {
+		if err := os.Mkdir(dir, 0o750); err != nil {
+			return newReconcileError(openfluxcdv1alpha1.StorageFailedReason, fmt.Errorf("failed to create working directory: %w", err))
+		}
+	}
+
+	// The timeout limits all fetches of the reconciliation together, i.e. the
+	// checksum and signature files as well as the URL and its mirrors.
+	fetchCtx, cancel := context.WithTimeout(ctx, r.timeout(obj))
+	defer cancel()
+
+	opts, err := r.fetchOptions(fetchCtx, obj)
+	if err != nil {
+		return err
+	}
+
+	opts = append(opts, fetcher.WithStagingDir(stagingDir))
+
+	locations, err := r.locations(fetchCtx, obj)
+	if err != nil {
+		return err
+	}
+
 	// reconcile the source and put it into the folder that the archive is going to serve.
-	digest, err := r.Fetcher.Fetch(ctx, obj.Spec.URL, tmpDir)
+	result, err := r.Fetcher.FetchFirst(fetchCtx, locations, contentDir, opts...)
 	if err != nil {
-		return ctrl.Result{}, fmt.Errorf("failed to fetch http source: %w", err)
+		// Record the revision of rejected content, so it can be told apart from the published one.
+		if digest := rejectedDigest(err); digest != "" {
+			obj.Status.LastAttemptedRevision = artifactRevision(obj, digest)
+		}
+
+		return fetchError(err)
 	}
 
+	if result.NotModified {
+		// The published Artifact is still up-to-date.
+		log.FromContext(ctx).Info("content not modified", "revision", obj.Status.LastAppliedRevision)
+		obj.Status.LastAttemptedRevision = obj.Status.LastAppliedRevision
+		obj.Status.LastAppliedURL = result.URL
+		setValidators(obj, result)
+
+		return nil
+	}
+
+	revision := artifactRevision(obj, result.Digest)
+	changed := revision != obj.Status.LastAppliedRevision
+
+	if changed {
+		r.EventRecorder.Eventf(obj, corev1.EventTypeNormal, openfluxcdv1alpha1.FetchSucceededReason,
+			"fetched revision '%s', previous revision '%s'", revision, obj.Status.LastAppliedRevision)
+	}
+
+	if obj.Spec.Verify != nil {
+		if changed {
+			r.EventRecorder.Eventf(obj, corev1.EventTypeNormal, openfluxcdv1alpha1.VerificationSucceededReason,
+				"verified revision '%s'", revision)
+		}
+
+		conditions.MarkTrue(obj, openfluxcdv1alpha1.SourceVerifiedCondition, meta.SucceededReason, "verified revision '%s'", revision)
+	} else {
+		conditions.Delete(obj, openfluxcdv1alpha1.SourceVerifiedCondition)
+	}
+
+	obj.Status.LastAttemptedRevision = revision
+
 	// Reconcile the storage to create the main location and prepare the server.
-	if err := r.Storage.ReconcileStorage(ctx, obj); err != nil {
-		return ctrl.Result{}, fmt.Errorf("failed to reconcile storage: %w", err)
+	storageCtx, storageSpan := tracing.Tracer().Start(ctx, "ReconcileStorage")
+	err = r.Storage.ReconcileStorage(storageCtx, obj)
+	tracing.End(storageSpan, err)
+
+	if err != nil {
+		return newReconcileError(openfluxcdv1alpha1.StorageFailedReason, fmt.Errorf("failed to reconcile storage: %w", err))
 	}
 
-	// Revision here is the hash of the content of the downloaded file for example.
-	if err := r.Storage.ReconcileArtifact(ctx, obj, digest, tmpDir, digest+".tar.gz", func(art *artifactv1.Artifact, s string) error {
+	var (
+		archiveErr error
+		published  bool
+	)
+
+	artifactCtx, artifactSpan := tracing.Tracer().Start(ctx, "ReconcileArtifact", trace.WithAttributes(
+		attribute.String("revision", revision),
+	))
+
+	// The revision is the hash of the downloaded content, combined with the fields that shape the Artifact.
+	err = r.Storage.ReconcileArtifact(artifactCtx, obj, revision, contentDir, revision+".tar.gz", func(art *artifactv1.Artifact, s string) error {
 		// Archive directory to storage
-		if err := r.Storage.Archive(art, tmpDir, nil); err != nil {
-			return fmt.Errorf("unable to archive artifact to storage: %w", err)
+		if err := r.Storage.Archive(art, contentDir, archiveFilter(obj, contentDir)); err != nil {
+			archiveErr = fmt.Errorf("unable to archive artifact to storage: %w", err)
+
+			return archiveErr
 		}
 
 		obj.Status.ArtifactName = art.Name
+		published = true
+
+		if art.Spec.Size != nil {
+			metrics.ArtifactSize.Observe(float64(*art.Spec.Size))
+		}
+
+		return nil
+	})
+	tracing.End(artifactSpan, err)
+
+	if err != nil {
+		if archiveErr != nil {
+			return newReconcileError(openfluxcdv1alpha1.ArchiveFailedReason, fmt.Errorf("failed to reconcile artifact: %w", err))
+		}
+
+		return newReconcileError(openfluxcdv1alpha1.StorageFailedReason, fmt.Errorf("failed to reconcile artifact: %w", err))
+	}
+
+	if published {
+		r.EventRecorder.Eventf(obj, corev1.EventTypeNormal, openfluxcdv1alpha1.NewArtifactReason,
+			"stored artifact '%s' for revision '%s', previous revision '%s'", obj.Status.ArtifactName, revision, obj.Status.LastAppliedRevision)
+	}
+
+	obj.Status.LastAppliedRevision = revision
+	obj.Status.LastAppliedURL = result.URL
+	obj.Status.ETag = ""
+	obj.Status.LastModified = ""
+	setValidators(obj, result)
+
+	return nil
+}
+
+// archiveFilter excludes the files matching spec.ignore in dir from the Artifact.
+func archiveFilter(obj *openfluxcdv1alpha1.Http, dir string) storage.ArchiveFileFilter {
+	if obj.Spec.Ignore == nil {
+		return nil
+	}
+
+	domain := strings.Split(dir, string(filepath.Separator))
+
+	return storage.SourceIgnoreFilter(sourceignore.ReadPatterns(strings.NewReader(*obj.Spec.Ignore), domain), domain)
+}
+
+// artifactRevision returns the revision of the Artifact built from content with the given digest.
+// Changing any field that shapes the Artifact changes the revision, so the Artifact is
+// rebuilt even if the content is unchanged. Objects that only use the defaults keep the
+// plain digest as revision, so their Artifacts are not republished.
+func artifactRevision(obj *openfluxcdv1alpha1.Http, digest string) string {
+	shape := struct {
+		Mode            string  `json:"mode,omitempty"`
+		Filename        string  `json:"filename,omitempty"`
+		Format          string  `json:"format,omitempty"`
+		StripComponents int     `json:"stripComponents,omitempty"`
+		Path            string  `json:"path,omitempty"`
+		Ignore          *string `json:"ignore,omitempty"`
+	}{
+		Filename:        obj.Spec.Filename,
+		Format:          obj.Spec.Format,
+		StripComponents: obj.Spec.StripComponents,
+		Path:            obj.Spec.Path,
+		Ignore:          obj.Spec.Ignore,
+	}
+
+	if obj.Spec.Mode != openfluxcdv1alpha1.ArchiveMode {
+		shape.Mode = obj.Spec.Mode
+	}
+
+	// Encoding a struct of strings and ints can't fail.
+	b, _ := json.Marshal(shape)
+	if string(b) == "{}" {
+		return digest
+	}
+
+	sum := sha256.Sum256(b)
+
+	return digest + "-" + hex.EncodeToString(sum[:8])
+}
+
+// conditionalOptions returns the validators of the last applied revision to
+// skip fetching unchanged content. They're only sent while the last applied
+// revision was built from the current spec, no reconciliation has been requested
+// and the Artifact of the revision is still in the storage.
+func (r *HttpReconciler) conditionalOptions(obj *openfluxcdv1alpha1.Http) []fetcher.FetchOptionsFn {
+	if obj.Status.LastAppliedRevision == "" || obj.Generation != obj.Status.ObservedGeneration {
+		return nil
+	}
+
+	// A stalled reconciliation observes the generation without applying it.
+	if !conditions.IsTrue(obj, meta.ReadyCondition) {
+		return nil
+	}
+
+	// The revision starts with the hex encoded digest of the content it was built from.
+	digest, _, _ := strings.Cut(obj.Status.LastAppliedRevision, "-")
+	if artifactRevision(obj, digest) != obj.Status.LastAppliedRevision {
+		return nil
+	}
+
+	if v, ok := meta.ReconcileAnnotationValue(obj.GetAnnotations()); ok && v != obj.Status.GetLastHandledReconcileRequest() {
+		return nil
+	}
+
+	revision := obj.Status.LastAppliedRevision
+	if !r.Storage.ArtifactExist(r.Storage.NewArtifactFor(obj.GetKind(), obj.GetObjectMeta(), revision, revision+".tar.gz")) {
+		return nil
+	}
+
+	var opts []fetcher.FetchOptionsFn
+	if obj.Status.ETag != "" {
+		opts = append(opts, fetcher.WithETag(obj.Status.ETag))
+	}
 
+	if obj.Status.LastModified != "" {
+		opts = append(opts, fetcher.WithLastModified(obj.Status.LastModified))
+	}
+
+	return opts
+}
+
+// setValidators stores the validators of the response, keeping the previous
+// ones if the server omitted them from a 304 Not Modified response.
+func setValidators(obj *openfluxcdv1alpha1.Http, result *fetcher.Result) {
+	if result.ETag != "" {
+		obj.Status.ETag = result.ETag
+	}
+
+	if result.LastModified != "" {
+		obj.Status.LastModified = result.LastModified
+	}
+}
+
+// reconcileDelete removes the Artifact and the stored files of the object and
+// releases the finalizer.
+func (r *HttpReconciler) reconcileDelete(ctx context.Context, obj *openfluxcdv1alpha1.Http) error {
+	if !controllerutil.ContainsFinalizer(obj, openfluxcdv1alpha1.HttpFinalizer) {
 		return nil
-	}); err != nil {
-		return ctrl.Result{}, fmt.Errorf("failed to reconcile artifact: %w", err)
 	}
 
-	return ctrl.Result{}, nil
+	artifact, err := r.findArtifact(ctx, obj)
+	if err != nil {
+		return err
+	}
+
+	if artifact != nil {
+		if err := r.Delete(ctx, artifact); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete artifact: %w", err)
+		}
+	}
+
+	dir, err := r.Storage.RemoveAll(r.Storage.NewArtifactFor(obj.GetKind(), obj.GetObjectMeta(), "", "*"))
+	if err != nil {
+		return fmt.Errorf("failed to remove stored artifacts: %w", err)
+	}
+
+	if dir != "" {
+		log.FromContext(ctx).Info("removed stored artifacts", "dir", dir)
+	}
+
+	r.Fetcher.RemoveTransport(client.ObjectKeyFromObject(obj).String())
+	metrics.DeleteObject(obj.Namespace, obj.Name)
+
+	controllerutil.RemoveFinalizer(obj, openfluxcdv1alpha1.HttpFinalizer)
+
+	return nil
+}
+
+// recordRevisionTimestamp records the time the published Artifact of the object was last updated.
+func (r *HttpReconciler) recordRevisionTimestamp(ctx context.Context, obj *openfluxcdv1alpha1.Http) {
+	artifact, err := r.findArtifact(ctx, obj)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to find artifact for metrics")
+
+		return
+	}
+
+	if artifact != nil {
+		metrics.SetRevisionTimestamp(obj.Namespace, obj.Name, artifact.Spec.LastUpdateTime.Time)
+	}
 }
 
 // SetupWithManager sets up the controller with the Manager.
-func (r *HttpReconciler) SetupWithManager(_ context.Context, mgr ctrl.Manager) error {
+func (r *HttpReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &openfluxcdv1alpha1.Http{}, secretRefsIndexKey, indexSecretRefs); err != nil {
+		return fmt.Errorf("failed to set index field '%s': %w", secretRefsIndexKey, err)
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&openfluxcdv1alpha1.Http{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		For(&openfluxcdv1alpha1.Http{}, builder.WithPredicates(
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicates.ReconcileRequestedPredicate{}),
+		)).
+		// Only the metadata of Secrets is cached, their data is read from the API server.
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForSecretChange),
+			builder.OnlyMetadata,
+			builder.WithPredicates(predicate.ResourceVersionChangedPredicate{}),
+		).
 		Complete(r)
 }
 
+// requestsForSecretChange enqueues every Http object in the namespace of the
+// Secret which references it, so rotated credentials are picked up immediately.
+func (r *HttpReconciler) requestsForSecretChange(ctx context.Context, obj client.Object) []reconcile.Request {
+	list := &openfluxcdv1alpha1.HttpList{}
+	if err := r.List(ctx, list,
+		client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{secretRefsIndexKey: obj.GetName()},
+	); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list http objects for secret", "secret", client.ObjectKeyFromObject(obj))
+
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for _, item := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&item)})
+	}
+
+	return requests
+}
+
 // this should most likely be extracted into the controller-manager
 func (r *HttpReconciler) findArtifact(ctx context.Context, object client.Object) (*artifactv1.Artifact, error) {
 	logger := log.FromContext(ctx).WithName("find-artifact")
@@ -142,8 +564,9 @@ func (r *HttpReconciler) findArtifact(ctx context.Context, object client.Object)
 			continue
 		}
 
+		// Objects of other kinds may have the same name, only the UID identifies the owner.
 		for _, owner := range artifact.OwnerReferences {
-			if owner.Name == object.GetName() {
+			if owner.UID == object.GetUID() {
 				return &artifact, nil
 			}
 		}
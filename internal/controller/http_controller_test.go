@@ -17,25 +17,50 @@ limitations under the License.
 package controller
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/runtime/conditions"
 	artifactv1 "github.com/openfluxcd/artifact/api/v1alpha1"
 	"github.com/openfluxcd/controller-manager/storage"
 	"github.com/openfluxcd/http-source-controller/api/v1alpha1"
 	"github.com/openfluxcd/http-source-controller/internal/fetcher"
+	"github.com/openfluxcd/http-source-controller/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/ptr"
 	controllerruntime "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 func TestHttpReconciler_Reconcile(t *testing.T) {
@@ -45,11 +70,13 @@ func TestHttpReconciler_Reconcile(t *testing.T) {
 
 	type fields struct {
 		Content       func(t *testing.T) []byte
+		Handler       func(t *testing.T, content []byte) http.HandlerFunc
 		Client        func(url string) client.Client
 		Scheme        *runtime.Scheme
 		Fetcher       func(client *http.Client) *fetcher.Fetcher
 		Storage       func(client.Client, *runtime.Scheme) *storage.Storage
 		AssertErr     func(t *testing.T, err error)
+		AssertResult  func(t *testing.T, result controllerruntime.Result)
 		AssertObjects func(t *testing.T, client client.Client)
 	}
 	type args struct {
@@ -98,7 +125,16 @@ func TestHttpReconciler_Reconcile(t *testing.T) {
 					// The base name must not be there because the file server already adds that.
 					assert.Equal(t, "http://hostname/http/default/test-http/93693d51d12553f1cab7202ae120c1e1f55783f384cdad0266eeaed7b565d1c2.tar.gz", artifact.Spec.URL)
 					assert.Equal(t, "93693d51d12553f1cab7202ae120c1e1f55783f384cdad0266eeaed7b565d1c2", artifact.Spec.Revision)
-					assert.Equal(t, int64(298), *artifact.Spec.Size)
+					assert.Equal(t, int64(140), *artifact.Spec.Size)
+
+					obj := &v1alpha1.Http{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "test-http", Namespace: "default"}, obj)
+					require.NoError(t, err)
+					assert.True(t, conditions.IsReady(obj))
+					assert.False(t, conditions.Has(obj, meta.ReconcilingCondition))
+					assert.Equal(t, obj.Generation, obj.Status.ObservedGeneration)
+					assert.Equal(t, "93693d51d12553f1cab7202ae120c1e1f55783f384cdad0266eeaed7b565d1c2", obj.Status.LastAttemptedRevision)
+					assert.Equal(t, "93693d51d12553f1cab7202ae120c1e1f55783f384cdad0266eeaed7b565d1c2", obj.Status.LastAppliedRevision)
 				},
 			},
 			args: args{
@@ -172,24 +208,2294 @@ func TestHttpReconciler_Reconcile(t *testing.T) {
 				},
 			},
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				w.Write(tt.fields.Content(t))
-			}))
-			defer testserver.Close()
-
-			c := tt.fields.Client(testserver.URL + "/content.tar.gz")
-			r := &HttpReconciler{
-				Client:  c,
-				Scheme:  tt.fields.Scheme,
-				Fetcher: tt.fields.Fetcher(testserver.Client()),
-				Storage: tt.fields.Storage(c, tt.fields.Scheme),
-			}
-			_, err := r.Reconcile(tt.args.ctx, tt.args.req)
-			tt.fields.AssertErr(t, err)
-			tt.fields.AssertObjects(t, c)
-		})
-	}
+		{
+			name: "should requeue after the interval",
+			fields: fields{
+				Client: func(url string) client.Client {
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-interval",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL:      url,
+								Interval: metav1.Duration{Duration: 5 * time.Minute},
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					content, err := os.ReadFile(filepath.Join("testdata", "content.tar.gz"))
+					require.NoError(t, err)
+					return content
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertResult: func(t *testing.T, result controllerruntime.Result) {
+					assert.Equal(t, 5*time.Minute, result.RequeueAfter)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-interval",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should requeue after the retry interval on failure",
+			fields: fields{
+				Client: func(url string) client.Client {
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-retry",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL:           url,
+								Interval:      metav1.Duration{Duration: 5 * time.Minute},
+								RetryInterval: &metav1.Duration{Duration: time.Minute},
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					return nil
+				},
+				Handler: func(t *testing.T, content []byte) http.HandlerFunc {
+					return func(w http.ResponseWriter, r *http.Request) {
+						w.WriteHeader(http.StatusServiceUnavailable)
+					}
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertResult: func(t *testing.T, result controllerruntime.Result) {
+					assert.Equal(t, time.Minute, result.RequeueAfter)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					obj := &v1alpha1.Http{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "test-http-retry", Namespace: "default"}, obj)
+					require.NoError(t, err)
+					assert.True(t, conditions.IsFalse(obj, meta.ReadyCondition))
+					assert.Equal(t, v1alpha1.FetchFailedReason, conditions.GetReason(obj, meta.ReadyCondition))
+					assert.True(t, conditions.IsReconciling(obj))
+					assert.False(t, conditions.IsStalled(obj))
+					assert.Empty(t, obj.Status.LastAppliedRevision)
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-retry",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should authenticate with credentials from the referenced secret",
+			fields: fields{
+				Client: func(url string) client.Client {
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-auth",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL:       url,
+								SecretRef: &meta.LocalObjectReference{Name: "http-credentials"},
+							},
+						}, &corev1.Secret{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "http-credentials",
+								Namespace: "default",
+							},
+							Data: map[string][]byte{
+								"username": []byte("user"),
+								"password": []byte("pass"),
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					content, err := os.ReadFile(filepath.Join("testdata", "content.tar.gz"))
+					require.NoError(t, err)
+					return content
+				},
+				Handler: func(t *testing.T, content []byte) http.HandlerFunc {
+					return func(w http.ResponseWriter, r *http.Request) {
+						username, password, ok := r.BasicAuth()
+						if !ok || username != "user" || password != "pass" {
+							w.WriteHeader(http.StatusUnauthorized)
+							return
+						}
+						w.Write(content)
+					}
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					artifact := &artifactv1.Artifact{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "http-default-test-http-auth", Namespace: "default"}, artifact)
+					require.NoError(t, err)
+					assert.Equal(t, "93693d51d12553f1cab7202ae120c1e1f55783f384cdad0266eeaed7b565d1c2", artifact.Spec.Revision)
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-auth",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should send the headers from the spec and the referenced secret",
+			fields: fields{
+				Client: func(url string) client.Client {
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-headers",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL: url,
+								Headers: map[string]string{
+									"Accept":        "application/octet-stream",
+									"PRIVATE-TOKEN": "overridden",
+								},
+								HeadersSecretRef: &meta.LocalObjectReference{Name: "http-headers"},
+							},
+						}, &corev1.Secret{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "http-headers",
+								Namespace: "default",
+							},
+							Data: map[string][]byte{
+								"PRIVATE-TOKEN": []byte("token"),
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					content, err := os.ReadFile(filepath.Join("testdata", "content.tar.gz"))
+					require.NoError(t, err)
+					return content
+				},
+				Handler: func(t *testing.T, content []byte) http.HandlerFunc {
+					return func(w http.ResponseWriter, r *http.Request) {
+						if r.Header.Get("Accept") != "application/octet-stream" || r.Header.Get("PRIVATE-TOKEN") != "token" {
+							w.WriteHeader(http.StatusUnauthorized)
+							return
+						}
+						w.Write(content)
+					}
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					obj := &v1alpha1.Http{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "test-http-headers", Namespace: "default"}, obj)
+					require.NoError(t, err)
+					assert.True(t, conditions.IsReady(obj))
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-headers",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should stall on invalid headers without revealing their values",
+			fields: fields{
+				Client: func(url string) client.Client {
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-invalid-headers",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL:              url,
+								HeadersSecretRef: &meta.LocalObjectReference{Name: "http-invalid-headers"},
+							},
+						}, &corev1.Secret{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "http-invalid-headers",
+								Namespace: "default",
+							},
+							Data: map[string][]byte{
+								"X-API-Key": []byte("secret-key\n"),
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					return nil
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					obj := &v1alpha1.Http{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "test-http-invalid-headers", Namespace: "default"}, obj)
+					require.NoError(t, err)
+					assert.True(t, conditions.IsStalled(obj))
+					assert.Equal(t, v1alpha1.AuthenticationFailedReason, conditions.GetReason(obj, meta.ReadyCondition))
+					assert.Contains(t, conditions.GetMessage(obj, meta.ReadyCondition), "X-API-Key")
+					assert.NotContains(t, conditions.GetMessage(obj, meta.ReadyCondition), "secret-key")
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-invalid-headers",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should fetch through the proxy from the referenced secret",
+			fields: fields{
+				Client: func(url string) client.Client {
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-proxy",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL:            "http://content.invalid/content.tar.gz",
+								ProxySecretRef: &meta.LocalObjectReference{Name: "http-proxy"},
+								NoProxy:        []string{"internal.example.com"},
+							},
+						}, &corev1.Secret{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "http-proxy",
+								Namespace: "default",
+							},
+							Data: map[string][]byte{
+								"address":  []byte(strings.TrimSuffix(url, "/content.tar.gz")),
+								"username": []byte("user"),
+								"password": []byte("pass"),
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					content, err := os.ReadFile(filepath.Join("testdata", "content.tar.gz"))
+					require.NoError(t, err)
+					return content
+				},
+				Handler: func(t *testing.T, content []byte) http.HandlerFunc {
+					return func(w http.ResponseWriter, r *http.Request) {
+						if r.URL.Host != "content.invalid" || r.Header.Get("Proxy-Authorization") == "" {
+							w.WriteHeader(http.StatusProxyAuthRequired)
+							return
+						}
+						w.Write(content)
+					}
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					obj := &v1alpha1.Http{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "test-http-proxy", Namespace: "default"}, obj)
+					require.NoError(t, err)
+					assert.True(t, conditions.IsReady(obj))
+					assert.Equal(t, "93693d51d12553f1cab7202ae120c1e1f55783f384cdad0266eeaed7b565d1c2", obj.Status.LastAppliedRevision)
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-proxy",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should stall on invalid credentials",
+			fields: fields{
+				Client: func(url string) client.Client {
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-stalled",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL:       url,
+								Interval:  metav1.Duration{Duration: 5 * time.Minute},
+								SecretRef: &meta.LocalObjectReference{Name: "http-invalid-credentials"},
+							},
+						}, &corev1.Secret{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "http-invalid-credentials",
+								Namespace: "default",
+							},
+							Data: map[string][]byte{
+								"username": []byte("user"),
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					return nil
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertResult: func(t *testing.T, result controllerruntime.Result) {
+					assert.Zero(t, result.RequeueAfter)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					obj := &v1alpha1.Http{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "test-http-stalled", Namespace: "default"}, obj)
+					require.NoError(t, err)
+					assert.True(t, conditions.IsStalled(obj))
+					assert.False(t, conditions.IsReconciling(obj))
+					assert.Equal(t, v1alpha1.AuthenticationFailedReason, conditions.GetReason(obj, meta.ReadyCondition))
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-stalled",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should publish the content as a single file in file mode",
+			fields: fields{
+				Client: func(url string) client.Client {
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-file",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL:      url,
+								Mode:     v1alpha1.FileMode,
+								Filename: "values.yaml",
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					return []byte("replicaCount: 2\n")
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					artifact := &artifactv1.Artifact{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "http-default-test-http-file", Namespace: "default"}, artifact)
+					require.NoError(t, err)
+
+					file, err := os.Open(filepath.Join(tmp, "http", "default", "test-http-file", artifact.Spec.Revision+".tar.gz"))
+					require.NoError(t, err)
+					defer file.Close()
+
+					assert.Equal(t, map[string]string{"values.yaml": "replicaCount: 2\n"}, readArtifact(t, file))
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-file",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should exclude ignored files from the artifact",
+			fields: fields{
+				Client: func(url string) client.Client {
+					ignore := "*.md\n/docs/\n"
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-ignore",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL:    url,
+								Ignore: &ignore,
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					return tarball(t, map[string]string{
+						"deploy.yaml":    "kind: Deployment\n",
+						"README.md":      "readme",
+						"docs/guide.txt": "guide",
+					})
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					artifact := &artifactv1.Artifact{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "http-default-test-http-ignore", Namespace: "default"}, artifact)
+					require.NoError(t, err)
+
+					file, err := os.Open(filepath.Join(tmp, "http", "default", "test-http-ignore", artifact.Spec.Revision+".tar.gz"))
+					require.NoError(t, err)
+					defer file.Close()
+
+					assert.Equal(t, map[string]string{"deploy.yaml": "kind: Deployment\n"}, readArtifact(t, file))
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-ignore",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should publish the selected directory of the archive",
+			fields: fields{
+				Client: func(url string) client.Client {
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-path",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL:             url,
+								StripComponents: 1,
+								Path:            "deploy",
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					return tarball(t, map[string]string{
+						"repo-1a2b3c/README.md":          "readme",
+						"repo-1a2b3c/deploy/deploy.yaml": "kind: Deployment\n",
+					})
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					artifact := &artifactv1.Artifact{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "http-default-test-http-path", Namespace: "default"}, artifact)
+					require.NoError(t, err)
+
+					file, err := os.Open(filepath.Join(tmp, "http", "default", "test-http-path", artifact.Spec.Revision+".tar.gz"))
+					require.NoError(t, err)
+					defer file.Close()
+
+					assert.Equal(t, map[string]string{"deploy.yaml": "kind: Deployment\n"}, readArtifact(t, file))
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-path",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should publish content matching the checksum file",
+			fields: fields{
+				Client: func(url string) client.Client {
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-checksum",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL: url,
+								Verify: &v1alpha1.HttpVerification{
+									ChecksumURL: strings.TrimSuffix(url, "content.tar.gz") + "SHA256SUMS",
+								},
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					content, err := os.ReadFile(filepath.Join("testdata", "content.tar.gz"))
+					require.NoError(t, err)
+					return content
+				},
+				Handler: func(t *testing.T, content []byte) http.HandlerFunc {
+					return func(w http.ResponseWriter, r *http.Request) {
+						if r.URL.Path == "/SHA256SUMS" {
+							w.Write([]byte("0000000000000000000000000000000000000000000000000000000000000000  other.tar.gz\n"))
+							w.Write([]byte("93693d51d12553f1cab7202ae120c1e1f55783f384cdad0266eeaed7b565d1c2 *content.tar.gz\n"))
+							return
+						}
+						w.Write(content)
+					}
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					obj := &v1alpha1.Http{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "test-http-checksum", Namespace: "default"}, obj)
+					require.NoError(t, err)
+					assert.True(t, conditions.IsReady(obj))
+					assert.Equal(t, "93693d51d12553f1cab7202ae120c1e1f55783f384cdad0266eeaed7b565d1c2", obj.Status.LastAppliedRevision)
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-checksum",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should refuse to publish content not matching the digest",
+			fields: fields{
+				Client: func(url string) client.Client {
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-digest",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL:           url,
+								RetryInterval: &metav1.Duration{Duration: time.Minute},
+								Verify: &v1alpha1.HttpVerification{
+									Digest: "sha512:" + strings.Repeat("0", 128),
+								},
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					content, err := os.ReadFile(filepath.Join("testdata", "content.tar.gz"))
+					require.NoError(t, err)
+					return content
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					artifact := &artifactv1.Artifact{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "http-default-test-http-digest", Namespace: "default"}, artifact)
+					assert.True(t, apierrors.IsNotFound(err))
+
+					obj := &v1alpha1.Http{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "test-http-digest", Namespace: "default"}, obj)
+					require.NoError(t, err)
+					assert.True(t, conditions.IsFalse(obj, meta.ReadyCondition))
+					assert.Equal(t, v1alpha1.VerificationFailedReason, conditions.GetReason(obj, meta.ReadyCondition))
+					assert.Contains(t, conditions.GetMessage(obj, meta.ReadyCondition), "digest mismatch")
+					assert.Empty(t, obj.Status.LastAppliedRevision)
+					assert.Equal(t, "93693d51d12553f1cab7202ae120c1e1f55783f384cdad0266eeaed7b565d1c2", obj.Status.LastAttemptedRevision)
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-digest",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should stall on not found",
+			fields: fields{
+				Client: func(url string) client.Client {
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-not-found",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL:           url,
+								Interval:      metav1.Duration{Duration: 5 * time.Minute},
+								RetryInterval: &metav1.Duration{Duration: time.Minute},
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					return nil
+				},
+				Handler: func(t *testing.T, content []byte) http.HandlerFunc {
+					return func(w http.ResponseWriter, r *http.Request) {
+						w.WriteHeader(http.StatusNotFound)
+					}
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertResult: func(t *testing.T, result controllerruntime.Result) {
+					assert.Zero(t, result.RequeueAfter)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					obj := &v1alpha1.Http{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "test-http-not-found", Namespace: "default"}, obj)
+					require.NoError(t, err)
+					assert.True(t, conditions.IsStalled(obj))
+					assert.False(t, conditions.IsReconciling(obj))
+					assert.Equal(t, v1alpha1.FetchFailedReason, conditions.GetReason(obj, meta.ReadyCondition))
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-not-found",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should stall on rejected credentials",
+			fields: fields{
+				Client: func(url string) client.Client {
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-unauthorized",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL:           url,
+								Interval:      metav1.Duration{Duration: 5 * time.Minute},
+								RetryInterval: &metav1.Duration{Duration: time.Minute},
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					return nil
+				},
+				Handler: func(t *testing.T, content []byte) http.HandlerFunc {
+					return func(w http.ResponseWriter, r *http.Request) {
+						w.WriteHeader(http.StatusUnauthorized)
+					}
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertResult: func(t *testing.T, result controllerruntime.Result) {
+					assert.Zero(t, result.RequeueAfter)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					obj := &v1alpha1.Http{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "test-http-unauthorized", Namespace: "default"}, obj)
+					require.NoError(t, err)
+					assert.True(t, conditions.IsStalled(obj))
+					assert.False(t, conditions.IsReconciling(obj))
+					assert.Equal(t, v1alpha1.AuthenticationFailedReason, conditions.GetReason(obj, meta.ReadyCondition))
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-unauthorized",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should stall on content which is not an archive",
+			fields: fields{
+				Client: func(url string) client.Client {
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-not-archive",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL:           url,
+								Interval:      metav1.Duration{Duration: 5 * time.Minute},
+								RetryInterval: &metav1.Duration{Duration: time.Minute},
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					return nil
+				},
+				Handler: func(t *testing.T, content []byte) http.HandlerFunc {
+					return func(w http.ResponseWriter, r *http.Request) {
+						w.Write([]byte("not an archive"))
+					}
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertResult: func(t *testing.T, result controllerruntime.Result) {
+					assert.Zero(t, result.RequeueAfter)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					obj := &v1alpha1.Http{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "test-http-not-archive", Namespace: "default"}, obj)
+					require.NoError(t, err)
+					assert.True(t, conditions.IsStalled(obj))
+					assert.False(t, conditions.IsReconciling(obj))
+					assert.Equal(t, v1alpha1.ExtractionFailedReason, conditions.GetReason(obj, meta.ReadyCondition))
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-not-archive",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should stall on content exceeding the max download size",
+			fields: fields{
+				Client: func(url string) client.Client {
+					maxDownloadSize := resource.MustParse("4")
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-too-large",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL:             url,
+								Interval:        metav1.Duration{Duration: 5 * time.Minute},
+								MaxDownloadSize: &maxDownloadSize,
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					return []byte("too large")
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertResult: func(t *testing.T, result controllerruntime.Result) {
+					assert.Zero(t, result.RequeueAfter)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					obj := &v1alpha1.Http{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "test-http-too-large", Namespace: "default"}, obj)
+					require.NoError(t, err)
+					assert.True(t, conditions.IsStalled(obj))
+					assert.Equal(t, v1alpha1.SizeLimitExceededReason, conditions.GetReason(obj, meta.ReadyCondition))
+					assert.Contains(t, conditions.GetMessage(obj, meta.ReadyCondition), "max download size of 4 bytes")
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-too-large",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should stall on an invalid file name",
+			fields: fields{
+				Client: func(url string) client.Client {
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-invalid-filename",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL:      url,
+								Interval: metav1.Duration{Duration: 5 * time.Minute},
+								Mode:     v1alpha1.FileMode,
+								Filename: "..",
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					return []byte("content")
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertResult: func(t *testing.T, result controllerruntime.Result) {
+					assert.Zero(t, result.RequeueAfter)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					obj := &v1alpha1.Http{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "test-http-invalid-filename", Namespace: "default"}, obj)
+					require.NoError(t, err)
+					assert.True(t, conditions.IsStalled(obj))
+					assert.Equal(t, v1alpha1.FetchFailedReason, conditions.GetReason(obj, meta.ReadyCondition))
+					assert.Contains(t, conditions.GetMessage(obj, meta.ReadyCondition), "invalid spec.filename '..'")
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-invalid-filename",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should stall on noProxy without proxySecretRef",
+			fields: fields{
+				Client: func(url string) client.Client {
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-no-proxy",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL:      url,
+								Interval: metav1.Duration{Duration: 5 * time.Minute},
+								NoProxy:  []string{"internal.example.com"},
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					return []byte("content")
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertResult: func(t *testing.T, result controllerruntime.Result) {
+					assert.Zero(t, result.RequeueAfter)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					obj := &v1alpha1.Http{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "test-http-no-proxy", Namespace: "default"}, obj)
+					require.NoError(t, err)
+					assert.True(t, conditions.IsStalled(obj))
+					assert.Equal(t, v1alpha1.FetchFailedReason, conditions.GetReason(obj, meta.ReadyCondition))
+					assert.Contains(t, conditions.GetMessage(obj, meta.ReadyCondition), "spec.noProxy requires spec.proxySecretRef")
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-no-proxy",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should stall on negative limits",
+			fields: fields{
+				Client: func(url string) client.Client {
+					maxExtractedSize := resource.MustParse("-1")
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-negative-limits",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL:              url,
+								Interval:         metav1.Duration{Duration: 5 * time.Minute},
+								MaxExtractedSize: &maxExtractedSize,
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					content, err := os.ReadFile(filepath.Join("testdata", "content.tar.gz"))
+					require.NoError(t, err)
+					return content
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertResult: func(t *testing.T, result controllerruntime.Result) {
+					assert.Zero(t, result.RequeueAfter)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					obj := &v1alpha1.Http{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "test-http-negative-limits", Namespace: "default"}, obj)
+					require.NoError(t, err)
+					assert.True(t, conditions.IsStalled(obj))
+					assert.Equal(t, v1alpha1.FetchFailedReason, conditions.GetReason(obj, meta.ReadyCondition))
+					assert.Empty(t, obj.Status.LastAppliedRevision)
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-negative-limits",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+		{
+			name: "should requeue after the wait the server asked for",
+			fields: fields{
+				Client: func(url string) client.Client {
+					return env.FakeKubeClient(
+						WithObjects(&v1alpha1.Http{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "test-http-retry-after",
+								Namespace: "default",
+							},
+							Spec: v1alpha1.HttpSpec{
+								URL:           url,
+								Interval:      metav1.Duration{Duration: 5 * time.Minute},
+								RetryInterval: &metav1.Duration{Duration: time.Minute},
+							},
+						}))
+				},
+				Content: func(t *testing.T) []byte {
+					return nil
+				},
+				Handler: func(t *testing.T, content []byte) http.HandlerFunc {
+					return func(w http.ResponseWriter, r *http.Request) {
+						w.Header().Set("Retry-After", "3600")
+						w.WriteHeader(http.StatusServiceUnavailable)
+					}
+				},
+				Scheme:  env.scheme,
+				Fetcher: func(client *http.Client) *fetcher.Fetcher { return fetcher.NewFetcher(client) },
+				Storage: func(c client.Client, scheme *runtime.Scheme) *storage.Storage {
+					s, _ := storage.NewStorage(c, scheme, tmp, "hostname", 0, 0)
+					return s
+				},
+				AssertErr: func(t *testing.T, err error) {
+					require.NoError(t, err)
+				},
+				AssertResult: func(t *testing.T, result controllerruntime.Result) {
+					assert.Equal(t, time.Hour, result.RequeueAfter)
+				},
+				AssertObjects: func(t *testing.T, client client.Client) {
+					obj := &v1alpha1.Http{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: "test-http-retry-after", Namespace: "default"}, obj)
+					require.NoError(t, err)
+					assert.False(t, conditions.IsStalled(obj))
+					assert.Equal(t, v1alpha1.FetchFailedReason, conditions.GetReason(obj, meta.ReadyCondition))
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				req: controllerruntime.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      "test-http-retry-after",
+						Namespace: "default",
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(tt.fields.Content(t))
+			})
+			if tt.fields.Handler != nil {
+				handler = tt.fields.Handler(t, tt.fields.Content(t))
+			}
+			testserver := httptest.NewServer(handler)
+			defer testserver.Close()
+
+			c := tt.fields.Client(testserver.URL + "/content.tar.gz")
+			r := &HttpReconciler{
+				Client:        c,
+				Scheme:        tt.fields.Scheme,
+				Fetcher:       tt.fields.Fetcher(testserver.Client()),
+				Storage:       tt.fields.Storage(c, tt.fields.Scheme),
+				EventRecorder: record.NewFakeRecorder(32),
+			}
+			result, err := r.Reconcile(tt.args.ctx, tt.args.req)
+			tt.fields.AssertErr(t, err)
+			if tt.fields.AssertResult != nil {
+				tt.fields.AssertResult(t, result)
+			}
+			tt.fields.AssertObjects(t, c)
+		})
+	}
+}
+
+func TestHttpReconciler_ReconcileWithCertSecret(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "test-reconcile-tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	content, err := os.ReadFile(filepath.Join("testdata", "content.tar.gz"))
+	require.NoError(t, err)
+
+	testserver := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer testserver.Close()
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: testserver.Certificate().Raw})
+
+	tests := []struct {
+		name      string
+		spec      v1alpha1.HttpSpec
+		assertErr func(t *testing.T, err error)
+	}{
+		{
+			name: "should trust the CA from the referenced secret",
+			spec: v1alpha1.HttpSpec{
+				URL:           testserver.URL + "/content.tar.gz",
+				CertSecretRef: &meta.LocalObjectReference{Name: "http-tls"},
+				MinTLSVersion: "1.2",
+			},
+			assertErr: func(t *testing.T, err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "should fail without the CA",
+			spec: v1alpha1.HttpSpec{
+				URL: testserver.URL + "/content.tar.gz",
+			},
+			assertErr: func(t *testing.T, err error) {
+				require.ErrorContains(t, err, "certificate")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := env.FakeKubeClient(
+				WithObjects(&v1alpha1.Http{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-http-tls",
+						Namespace: "default",
+					},
+					Spec: tt.spec,
+				}, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "http-tls",
+						Namespace: "default",
+					},
+					Data: map[string][]byte{
+						"ca.crt": caPEM,
+					},
+				}))
+			s, err := storage.NewStorage(c, env.scheme, tmp, "hostname", 0, 0)
+			require.NoError(t, err)
+
+			r := &HttpReconciler{
+				Client:        c,
+				Scheme:        env.scheme,
+				Fetcher:       fetcher.NewFetcher(&http.Client{}),
+				Storage:       s,
+				EventRecorder: record.NewFakeRecorder(32),
+			}
+			_, err = r.Reconcile(context.Background(), controllerruntime.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      "test-http-tls",
+					Namespace: "default",
+				},
+			})
+			tt.assertErr(t, err)
+		})
+	}
+}
+
+func TestHttpReconciler_ReconcileWithSignature(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "test-reconcile-signature")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	content, err := os.ReadFile(filepath.Join("testdata", "content.tar.gz"))
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})
+
+	sign := func(t *testing.T, content []byte) string {
+		digest := sha256.Sum256(content)
+		sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+
+		return base64.StdEncoding.EncodeToString(sig)
+	}
+
+	testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/content.tar.gz.sig":
+			w.Write([]byte(sign(t, content) + "\n"))
+		case "/content.tar.gz.bundle":
+			w.Write([]byte(`{"base64Signature":"` + sign(t, content) + `"}`))
+		case "/other.sig":
+			w.Write([]byte(sign(t, []byte("other content"))))
+		default:
+			w.Write(content)
+		}
+	}))
+	defer testserver.Close()
+
+	tests := []struct {
+		name          string
+		verify        *v1alpha1.HttpVerification
+		assertObjects func(t *testing.T, obj *v1alpha1.Http)
+	}{
+		{
+			name: "should publish content with a valid signature",
+			verify: &v1alpha1.HttpVerification{
+				Provider:     v1alpha1.CosignProvider,
+				SecretRef:    &meta.LocalObjectReference{Name: "cosign-keys"},
+				SignatureURL: testserver.URL + "/content.tar.gz.sig",
+			},
+			assertObjects: func(t *testing.T, obj *v1alpha1.Http) {
+				assert.True(t, conditions.IsReady(obj))
+				assert.True(t, conditions.IsTrue(obj, v1alpha1.SourceVerifiedCondition))
+			},
+		},
+		{
+			name: "should publish content with a valid signature bundle",
+			verify: &v1alpha1.HttpVerification{
+				Provider:  v1alpha1.CosignProvider,
+				SecretRef: &meta.LocalObjectReference{Name: "cosign-keys"},
+				BundleURL: testserver.URL + "/content.tar.gz.bundle",
+			},
+			assertObjects: func(t *testing.T, obj *v1alpha1.Http) {
+				assert.True(t, conditions.IsReady(obj))
+				assert.True(t, conditions.IsTrue(obj, v1alpha1.SourceVerifiedCondition))
+			},
+		},
+		{
+			name: "should refuse to publish content with an invalid signature",
+			verify: &v1alpha1.HttpVerification{
+				Provider:     v1alpha1.CosignProvider,
+				SecretRef:    &meta.LocalObjectReference{Name: "cosign-keys"},
+				SignatureURL: testserver.URL + "/other.sig",
+			},
+			assertObjects: func(t *testing.T, obj *v1alpha1.Http) {
+				assert.True(t, conditions.IsFalse(obj, meta.ReadyCondition))
+				assert.True(t, conditions.IsFalse(obj, v1alpha1.SourceVerifiedCondition))
+				assert.Equal(t, v1alpha1.VerificationFailedReason, conditions.GetReason(obj, v1alpha1.SourceVerifiedCondition))
+				assert.Empty(t, obj.Status.LastAppliedRevision)
+				assert.Equal(t, "93693d51d12553f1cab7202ae120c1e1f55783f384cdad0266eeaed7b565d1c2", obj.Status.LastAttemptedRevision)
+			},
+		},
+		{
+			name: "should stall without public keys",
+			verify: &v1alpha1.HttpVerification{
+				Provider:     v1alpha1.CosignProvider,
+				SecretRef:    &meta.LocalObjectReference{Name: "no-keys"},
+				SignatureURL: testserver.URL + "/content.tar.gz.sig",
+			},
+			assertObjects: func(t *testing.T, obj *v1alpha1.Http) {
+				assert.True(t, conditions.IsStalled(obj))
+				assert.Equal(t, v1alpha1.VerificationFailedReason, conditions.GetReason(obj, meta.ReadyCondition))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := env.FakeKubeClient(
+				WithObjects(&v1alpha1.Http{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-http-signature",
+						Namespace: "default",
+					},
+					Spec: v1alpha1.HttpSpec{
+						URL:           testserver.URL + "/content.tar.gz",
+						RetryInterval: &metav1.Duration{Duration: time.Minute},
+						Verify:        tt.verify,
+					},
+				}, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cosign-keys",
+						Namespace: "default",
+					},
+					Data: map[string][]byte{
+						"cosign.pub": publicKeyPEM,
+					},
+				}, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "no-keys",
+						Namespace: "default",
+					},
+					Data: map[string][]byte{
+						"cosign.key": []byte("private"),
+					},
+				}))
+			s, err := storage.NewStorage(c, env.scheme, tmp, "hostname", 0, 0)
+			require.NoError(t, err)
+
+			r := &HttpReconciler{
+				Client:        c,
+				Scheme:        env.scheme,
+				Fetcher:       fetcher.NewFetcher(testserver.Client()),
+				Storage:       s,
+				EventRecorder: record.NewFakeRecorder(32),
+			}
+			_, err = r.Reconcile(context.Background(), controllerruntime.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      "test-http-signature",
+					Namespace: "default",
+				},
+			})
+			require.NoError(t, err)
+
+			obj := &v1alpha1.Http{}
+			require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "test-http-signature", Namespace: "default"}, obj))
+			tt.assertObjects(t, obj)
+		})
+	}
+}
+
+func TestHttpReconciler_ReconcileNotModified(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "test-reconcile-not-modified")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	content, err := os.ReadFile(filepath.Join("testdata", "content.tar.gz"))
+	require.NoError(t, err)
+
+	const etag = `"v1"`
+	sum := sha256.Sum256(content)
+	downloads, checksums := 0, 0
+	checksumStatus := http.StatusOK
+	testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/SHA256SUMS" {
+			checksums++
+			w.WriteHeader(checksumStatus)
+			w.Write([]byte(hex.EncodeToString(sum[:]) + "  content.tar.gz\n"))
+			return
+		}
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Write(content)
+	}))
+	defer testserver.Close()
+
+	c := env.FakeKubeClient(
+		WithObjects(&v1alpha1.Http{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-http-not-modified",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.HttpSpec{
+				URL: testserver.URL + "/content.tar.gz",
+				Verify: &v1alpha1.HttpVerification{
+					ChecksumURL: testserver.URL + "/SHA256SUMS",
+				},
+			},
+		}))
+	s, err := storage.NewStorage(c, env.scheme, tmp, "hostname", 0, 0)
+	require.NoError(t, err)
+
+	r := &HttpReconciler{
+		Client:        c,
+		Scheme:        env.scheme,
+		Fetcher:       fetcher.NewFetcher(testserver.Client()),
+		Storage:       s,
+		EventRecorder: record.NewFakeRecorder(32),
+	}
+	req := controllerruntime.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "test-http-not-modified",
+			Namespace: "default",
+		},
+	}
+
+	for range 2 {
+		_, err = r.Reconcile(context.Background(), req)
+		require.NoError(t, err)
+	}
+
+	// The checksum file is only fetched for modified content.
+	assert.Equal(t, 1, downloads)
+	assert.Equal(t, 1, checksums)
+
+	// Unmodified content doesn't depend on the checksum file.
+	checksumStatus = http.StatusInternalServerError
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, 1, checksums)
+	checksumStatus = http.StatusOK
+
+	obj := &v1alpha1.Http{}
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, obj))
+	assert.True(t, conditions.IsReady(obj))
+	assert.Equal(t, etag, obj.Status.ETag)
+	assert.Equal(t, "93693d51d12553f1cab7202ae120c1e1f55783f384cdad0266eeaed7b565d1c2", obj.Status.LastAppliedRevision)
+	assert.Equal(t, obj.Status.LastAppliedRevision, obj.Status.LastAttemptedRevision)
+
+	// A requested reconciliation fetches the content again, but only once.
+	obj.SetAnnotations(map[string]string{meta.ReconcileRequestAnnotation: "now"})
+	require.NoError(t, c.Update(context.Background(), obj))
+	for range 2 {
+		_, err = r.Reconcile(context.Background(), req)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, downloads)
+	assert.Equal(t, 2, checksums)
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, obj))
+	assert.Equal(t, "now", obj.Status.LastHandledReconcileAt)
+
+	// A lost Artifact is fetched again.
+	_, err = s.RemoveAll(s.NewArtifactFor(obj.GetKind(), obj.GetObjectMeta(), "", "*"))
+	require.NoError(t, err)
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, 3, downloads)
+}
+
+func TestHttpReconciler_ReconcileNotModifiedAfterStall(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "test-reconcile-not-modified-after-stall")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	content, err := os.ReadFile(filepath.Join("testdata", "content.tar.gz"))
+	require.NoError(t, err)
+
+	const etag = `"v1"`
+	downloads := 0
+	testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Write(content)
+	}))
+	defer testserver.Close()
+
+	c := env.FakeKubeClient(
+		WithObjects(&v1alpha1.Http{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-http-not-modified-after-stall",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.HttpSpec{
+				URL: testserver.URL + "/content.tar.gz",
+			},
+		}, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "auth",
+				Namespace: "default",
+			},
+			Data: map[string][]byte{
+				"username": []byte("user"),
+			},
+		}))
+	s, err := storage.NewStorage(c, env.scheme, tmp, "hostname", 0, 0)
+	require.NoError(t, err)
+
+	r := &HttpReconciler{
+		Client:        c,
+		Scheme:        env.scheme,
+		Fetcher:       fetcher.NewFetcher(testserver.Client()),
+		Storage:       s,
+		EventRecorder: record.NewFakeRecorder(32),
+	}
+	req := controllerruntime.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "test-http-not-modified-after-stall",
+			Namespace: "default",
+		},
+	}
+
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, 1, downloads)
+
+	obj := &v1alpha1.Http{}
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, obj))
+	previous := obj.Status.LastAppliedRevision
+
+	// The spec change stalls on the incomplete Secret.
+	obj.Spec.StripComponents = 1
+	obj.Spec.SecretRef = &meta.LocalObjectReference{Name: "auth"}
+	require.NoError(t, c.Update(context.Background(), obj))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, obj))
+	assert.True(t, conditions.IsStalled(obj))
+	assert.Equal(t, obj.Generation, obj.Status.ObservedGeneration)
+
+	// Once the Secret is fixed, the content is fetched again to apply the spec change.
+	secret := &corev1.Secret{}
+	require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "auth", Namespace: "default"}, secret))
+	secret.Data["password"] = []byte("pass")
+	require.NoError(t, c.Update(context.Background(), secret))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, 2, downloads)
+
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, obj))
+	assert.True(t, conditions.IsReady(obj))
+	assert.Equal(t, artifactRevision(obj, previous), obj.Status.LastAppliedRevision)
+	assert.NotEqual(t, previous, obj.Status.LastAppliedRevision)
+
+	// The validators are sent again once the spec has been applied.
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, 2, downloads)
+}
+
+func TestHttpReconciler_ReconcileSpecChange(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "test-reconcile-spec-change")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	content := tarball(t, map[string]string{
+		"base/base.yaml":     "kind: Base\n",
+		"deploy/deploy.yaml": "kind: Deployment\n",
+	})
+	testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer testserver.Close()
+
+	c := env.FakeKubeClient(
+		WithObjects(&v1alpha1.Http{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-http-spec-change",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.HttpSpec{
+				URL:  testserver.URL + "/content.tar.gz",
+				Path: "base",
+			},
+		}))
+	s, err := storage.NewStorage(c, env.scheme, tmp, "hostname", 0, 0)
+	require.NoError(t, err)
+
+	r := &HttpReconciler{
+		Client:        c,
+		Scheme:        env.scheme,
+		Fetcher:       fetcher.NewFetcher(testserver.Client()),
+		Storage:       s,
+		EventRecorder: record.NewFakeRecorder(32),
+	}
+	req := controllerruntime.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "test-http-spec-change",
+			Namespace: "default",
+		},
+	}
+
+	published := func() map[string]string {
+		artifact := &artifactv1.Artifact{}
+		require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "http-default-test-http-spec-change", Namespace: "default"}, artifact))
+
+		file, err := os.Open(filepath.Join(tmp, "http", "default", "test-http-spec-change", artifact.Spec.Revision+".tar.gz"))
+		require.NoError(t, err)
+		defer file.Close()
+
+		return readArtifact(t, file)
+	}
+
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"base.yaml": "kind: Base\n"}, published())
+
+	obj := &v1alpha1.Http{}
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, obj))
+	previous := obj.Status.LastAppliedRevision
+
+	// The content is unchanged, but the Artifact is rebuilt from the new path.
+	obj.Spec.Path = "deploy"
+	require.NoError(t, c.Update(context.Background(), obj))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"deploy.yaml": "kind: Deployment\n"}, published())
+
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, obj))
+	assert.NotEqual(t, previous, obj.Status.LastAppliedRevision)
+	assert.Equal(t, obj.Status.LastAppliedRevision, obj.Status.LastAttemptedRevision)
+	previous = obj.Status.LastAppliedRevision
+
+	// Ignored files are removed from the Artifact right away.
+	obj.Spec.Ignore = ptr.To("*.yaml")
+	require.NoError(t, c.Update(context.Background(), obj))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Empty(t, published())
+
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, obj))
+	assert.NotEqual(t, previous, obj.Status.LastAppliedRevision)
+
+	// Switching the mode publishes the fetched content as it is.
+	obj.Spec.Mode = v1alpha1.FileMode
+	obj.Spec.Path = ""
+	obj.Spec.Ignore = nil
+	require.NoError(t, c.Update(context.Background(), obj))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"content.tar.gz": string(content)}, published())
+
+	// Renaming the file rebuilds the Artifact as well.
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, obj))
+	obj.Spec.Filename = "manifests.tar.gz"
+	require.NoError(t, c.Update(context.Background(), obj))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"manifests.tar.gz": string(content)}, published())
+}
+
+func TestHttpReconciler_ReconcileMirrors(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "test-reconcile-mirrors")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	content, err := os.ReadFile(filepath.Join("testdata", "content.tar.gz"))
+	require.NoError(t, err)
+
+	testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/primary/") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		// The credentials of the URL must not be sent to the mirror.
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "mirror" || password != "mirror-pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(content)
+	}))
+	defer testserver.Close()
+
+	mirrorURL := testserver.URL + "/mirror/content.tar.gz"
+	c := env.FakeKubeClient(
+		WithObjects(&v1alpha1.Http{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-http-mirrors",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.HttpSpec{
+				URL:       testserver.URL + "/primary/content.tar.gz",
+				SecretRef: &meta.LocalObjectReference{Name: "primary-credentials"},
+				Mirrors: []v1alpha1.HttpMirror{
+					{URL: testserver.URL + "/unauthenticated/content.tar.gz"},
+					{URL: mirrorURL, SecretRef: &meta.LocalObjectReference{Name: "mirror-credentials"}},
+				},
+				Verify: &v1alpha1.HttpVerification{
+					Digest: "sha256:93693d51d12553f1cab7202ae120c1e1f55783f384cdad0266eeaed7b565d1c2",
+				},
+			},
+		}, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "primary-credentials", Namespace: "default"},
+			Data: map[string][]byte{
+				"username": []byte("primary"),
+				"password": []byte("primary-pass"),
+			},
+		}, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "mirror-credentials", Namespace: "default"},
+			Data: map[string][]byte{
+				"username": []byte("mirror"),
+				"password": []byte("mirror-pass"),
+			},
+		}))
+	s, err := storage.NewStorage(c, env.scheme, tmp, "hostname", 0, 0)
+	require.NoError(t, err)
+
+	r := &HttpReconciler{
+		Client:        c,
+		Scheme:        env.scheme,
+		Fetcher:       fetcher.NewFetcher(testserver.Client()),
+		Storage:       s,
+		EventRecorder: record.NewFakeRecorder(32),
+	}
+	req := controllerruntime.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "test-http-mirrors",
+			Namespace: "default",
+		},
+	}
+
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+
+	obj := &v1alpha1.Http{}
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, obj))
+	assert.True(t, conditions.IsReady(obj))
+	assert.Equal(t, "93693d51d12553f1cab7202ae120c1e1f55783f384cdad0266eeaed7b565d1c2", obj.Status.LastAppliedRevision)
+	assert.Equal(t, mirrorURL, obj.Status.LastAppliedURL)
+}
+
+func TestHttpReconciler_ReconcileSuspend(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "test-reconcile-suspend")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	content, err := os.ReadFile(filepath.Join("testdata", "content.tar.gz"))
+	require.NoError(t, err)
+
+	downloads := 0
+	testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		w.Write(content)
+	}))
+	defer testserver.Close()
+
+	c := env.FakeKubeClient(
+		WithObjects(&v1alpha1.Http{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-http-suspend",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.HttpSpec{
+				URL:      testserver.URL + "/content.tar.gz",
+				Interval: metav1.Duration{Duration: 5 * time.Minute},
+			},
+		}))
+	s, err := storage.NewStorage(c, env.scheme, tmp, "hostname", 0, 0)
+	require.NoError(t, err)
+
+	r := &HttpReconciler{
+		Client:        c,
+		Scheme:        env.scheme,
+		Fetcher:       fetcher.NewFetcher(testserver.Client()),
+		Storage:       s,
+		EventRecorder: record.NewFakeRecorder(32),
+	}
+	req := controllerruntime.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "test-http-suspend",
+			Namespace: "default",
+		},
+	}
+
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+
+	setSuspend := func(suspend bool) {
+		obj := &v1alpha1.Http{}
+		require.NoError(t, c.Get(context.Background(), req.NamespacedName, obj))
+		obj.Spec.Suspend = suspend
+		require.NoError(t, c.Update(context.Background(), obj))
+	}
+
+	// A suspended object isn't fetched, nor requeued, and its Artifact is still served.
+	setSuspend(true)
+	result, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Equal(t, 1, downloads)
+
+	obj := &v1alpha1.Http{}
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, obj))
+	assert.True(t, conditions.IsReady(obj))
+	assert.Equal(t, meta.SuspendedReason, conditions.GetReason(obj, meta.ReadyCondition))
+	assert.True(t, s.ArtifactExist(s.NewArtifactFor(obj.GetKind(), obj.GetObjectMeta(), obj.Status.LastAppliedRevision, obj.Status.LastAppliedRevision+".tar.gz")))
+
+	// Resuming fetches the URL again and requeues after the interval.
+	setSuspend(false)
+	result, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.NotZero(t, result.RequeueAfter)
+	assert.Equal(t, 2, downloads)
+
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, obj))
+	assert.True(t, conditions.IsReady(obj))
+	assert.Equal(t, meta.SucceededReason, conditions.GetReason(obj, meta.ReadyCondition))
+}
+
+func TestHttpReconciler_ReconcileEvents(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "test-reconcile-events")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	content, err := os.ReadFile(filepath.Join("testdata", "content.tar.gz"))
+	require.NoError(t, err)
+
+	available := true
+	testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(content)
+	}))
+	defer testserver.Close()
+
+	c := env.FakeKubeClient(
+		WithObjects(&v1alpha1.Http{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-http-events",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.HttpSpec{
+				URL:           testserver.URL + "/content.tar.gz",
+				RetryInterval: &metav1.Duration{Duration: time.Minute},
+			},
+		}))
+	s, err := storage.NewStorage(c, env.scheme, tmp, "hostname", 0, 0)
+	require.NoError(t, err)
+
+	recorder := record.NewFakeRecorder(32)
+	r := &HttpReconciler{
+		Client:        c,
+		Scheme:        env.scheme,
+		Fetcher:       fetcher.NewFetcher(testserver.Client()),
+		Storage:       s,
+		EventRecorder: recorder,
+	}
+	req := controllerruntime.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "test-http-events",
+			Namespace: "default",
+		},
+	}
+
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "Normal FetchSucceeded fetched revision '93693d51d12553f1cab7202ae120c1e1f55783f384cdad0266eeaed7b565d1c2', previous revision ''", <-recorder.Events)
+	assert.Equal(t, "Normal NewArtifact stored artifact 'http-default-test-http-events' for revision '93693d51d12553f1cab7202ae120c1e1f55783f384cdad0266eeaed7b565d1c2', previous revision ''", <-recorder.Events)
+
+	// An unchanged revision emits no events.
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Empty(t, recorder.Events)
+
+	available = false
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Contains(t, <-recorder.Events, "Warning FetchFailed failed to fetch http source")
+}
+
+func TestHttpReconciler_ReconcileMetrics(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "test-reconcile-metrics")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	content, err := os.ReadFile(filepath.Join("testdata", "content.tar.gz"))
+	require.NoError(t, err)
+
+	testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer testserver.Close()
+
+	c := env.FakeKubeClient(
+		WithObjects(&v1alpha1.Http{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-http-metrics",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.HttpSpec{
+				URL: testserver.URL + "/content.tar.gz",
+			},
+		}))
+	s, err := storage.NewStorage(c, env.scheme, tmp, "hostname", 0, 0)
+	require.NoError(t, err)
+
+	r := &HttpReconciler{
+		Client:        c,
+		Scheme:        env.scheme,
+		Fetcher:       fetcher.NewFetcher(testserver.Client()),
+		Storage:       s,
+		EventRecorder: record.NewFakeRecorder(32),
+	}
+	_, err = r.Reconcile(context.Background(), controllerruntime.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "test-http-metrics",
+			Namespace: "default",
+		},
+	})
+	require.NoError(t, err)
+
+	host := strings.TrimPrefix(testserver.URL, "http://")
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.Responses.WithLabelValues(host, "200")))
+
+	artifact := &artifactv1.Artifact{}
+	require.NoError(t, c.Get(context.TODO(), types.NamespacedName{Name: "http-default-test-http-metrics", Namespace: "default"}, artifact))
+	assert.Equal(t, float64(artifact.Spec.LastUpdateTime.Unix()), testutil.ToFloat64(metrics.RevisionTimestamp.WithLabelValues("default", "test-http-metrics")))
+}
+
+func TestHttpReconciler_ReconcileDelete(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "test-reconcile-delete")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	obj := &v1alpha1.Http{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "test-http-delete",
+			Namespace:         "default",
+			UID:               "test-uid",
+			Finalizers:        []string{v1alpha1.HttpFinalizer},
+			DeletionTimestamp: ptr.To(metav1.Now()),
+		},
+		Spec: v1alpha1.HttpSpec{
+			URL: "http://localhost/content.tar.gz",
+		},
+	}
+	c := env.FakeKubeClient(
+		WithObjects(obj, &artifactv1.Artifact{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "http-default-test-http-delete",
+				Namespace: "default",
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: v1alpha1.GroupVersion.String(),
+						Kind:       "Http",
+						Name:       "test-http-delete",
+						UID:        "test-uid",
+					},
+				},
+			},
+		}, &artifactv1.Artifact{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "gitrepository-default-test-http-delete",
+				Namespace: "default",
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: "source.toolkit.fluxcd.io/v1",
+						Kind:       "GitRepository",
+						Name:       "test-http-delete",
+						UID:        "other-uid",
+					},
+				},
+			},
+		}))
+	s, err := storage.NewStorage(c, env.scheme, tmp, "hostname", 0, 0)
+	require.NoError(t, err)
+
+	metrics.SetRevisionTimestamp("default", "test-http-delete", time.Now())
+
+	dir := filepath.Join(tmp, "http", "default", "test-http-delete")
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "revision.tar.gz"), []byte("content"), 0o600))
+
+	r := &HttpReconciler{
+		Client:        c,
+		Scheme:        env.scheme,
+		Fetcher:       fetcher.NewFetcher(&http.Client{}),
+		Storage:       s,
+		EventRecorder: record.NewFakeRecorder(32),
+	}
+	_, err = r.Reconcile(context.Background(), controllerruntime.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "test-http-delete",
+			Namespace: "default",
+		},
+	})
+	require.NoError(t, err)
+
+	assert.True(t, apierrors.IsNotFound(c.Get(context.TODO(), client.ObjectKeyFromObject(obj), &v1alpha1.Http{})))
+	assert.True(t, apierrors.IsNotFound(c.Get(context.TODO(), types.NamespacedName{Name: "http-default-test-http-delete", Namespace: "default"}, &artifactv1.Artifact{})))
+	assert.NoError(t, c.Get(context.TODO(), types.NamespacedName{Name: "gitrepository-default-test-http-delete", Namespace: "default"}, &artifactv1.Artifact{}))
+	assert.NoDirExists(t, dir)
+	assert.False(t, metrics.RevisionTimestamp.DeleteLabelValues("default", "test-http-delete"))
+}
+
+// readArtifact returns the content of all files in an artifact tarball.
+func readArtifact(t *testing.T, r io.Reader) map[string]string {
+	gr, err := gzip.NewReader(r)
+	require.NoError(t, err)
+
+	files := map[string]string{}
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = string(content)
+	}
+
+	return files
+}
+
+// tarball returns a gzipped tarball with the given files.
+func tarball(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+
+	return buf.Bytes()
+}
+
+func TestHttpReconciler_RequestsForSecretChange(t *testing.T) {
+	ref := func(name string) *meta.LocalObjectReference {
+		return &meta.LocalObjectReference{Name: name}
+	}
+
+	objects := []client.Object{
+		&v1alpha1.Http{
+			ObjectMeta: metav1.ObjectMeta{Name: "auth", Namespace: "default"},
+			Spec:       v1alpha1.HttpSpec{SecretRef: ref("shared"), HeadersSecretRef: ref("headers")},
+		},
+		&v1alpha1.Http{
+			ObjectMeta: metav1.ObjectMeta{Name: "mirror", Namespace: "default"},
+			Spec:       v1alpha1.HttpSpec{Mirrors: []v1alpha1.HttpMirror{{URL: "https://mirror", SecretRef: ref("shared")}}},
+		},
+		&v1alpha1.Http{
+			ObjectMeta: metav1.ObjectMeta{Name: "cert", Namespace: "default"},
+			Spec:       v1alpha1.HttpSpec{CertSecretRef: ref("shared")},
+		},
+		&v1alpha1.Http{
+			ObjectMeta: metav1.ObjectMeta{Name: "proxy", Namespace: "default"},
+			Spec:       v1alpha1.HttpSpec{ProxySecretRef: ref("shared")},
+		},
+		&v1alpha1.Http{
+			ObjectMeta: metav1.ObjectMeta{Name: "verify", Namespace: "default"},
+			Spec:       v1alpha1.HttpSpec{Verify: &v1alpha1.HttpVerification{SecretRef: ref("shared")}},
+		},
+		&v1alpha1.Http{
+			ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "default"},
+			Spec:       v1alpha1.HttpSpec{SecretRef: ref("other")},
+		},
+		&v1alpha1.Http{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-namespace", Namespace: "other"},
+			Spec:       v1alpha1.HttpSpec{SecretRef: ref("shared")},
+		},
+	}
+
+	r := &HttpReconciler{
+		Client: fake.NewClientBuilder().
+			WithScheme(env.scheme).
+			WithObjects(objects...).
+			WithIndex(&v1alpha1.Http{}, secretRefsIndexKey, indexSecretRefs).
+			Build(),
+	}
+
+	// The Secret watch only delivers the metadata of the Secret.
+	secret := &metav1.PartialObjectMetadata{
+		ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "default"},
+	}
+
+	var names []string
+	for _, req := range r.requestsForSecretChange(context.Background(), secret) {
+		assert.Equal(t, "default", req.Namespace)
+		names = append(names, req.Name)
+	}
+
+	assert.ElementsMatch(t, []string{"auth", "mirror", "cert", "proxy", "verify"}, names)
+
+	secret.Name = "headers"
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "auth", Namespace: "default"}}},
+		r.requestsForSecretChange(context.Background(), secret))
+}
+
+func TestHttpReconciler_ReconcileTimeout(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "test-reconcile-timeout")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	content, err := os.ReadFile(filepath.Join("testdata", "content.tar.gz"))
+	require.NoError(t, err)
+
+	sum := sha256.Sum256(content)
+	testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Each response fits into the timeout, but not both together.
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(150 * time.Millisecond):
+		}
+
+		if r.URL.Path == "/SHA256SUMS" {
+			w.Write([]byte(hex.EncodeToString(sum[:]) + "  content.tar.gz\n"))
+			return
+		}
+		w.Write(content)
+	}))
+	defer testserver.Close()
+
+	c := env.FakeKubeClient(
+		WithObjects(&v1alpha1.Http{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-http-timeout",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.HttpSpec{
+				URL:     testserver.URL + "/content.tar.gz",
+				Timeout: &metav1.Duration{Duration: 200 * time.Millisecond},
+				Verify: &v1alpha1.HttpVerification{
+					ChecksumURL: testserver.URL + "/SHA256SUMS",
+				},
+			},
+		}))
+	s, err := storage.NewStorage(c, env.scheme, tmp, "hostname", 0, 0)
+	require.NoError(t, err)
+
+	r := &HttpReconciler{
+		Client:        c,
+		Scheme:        env.scheme,
+		Fetcher:       fetcher.NewFetcher(testserver.Client()),
+		Storage:       s,
+		EventRecorder: record.NewFakeRecorder(32),
+	}
+	req := controllerruntime.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "test-http-timeout",
+			Namespace: "default",
+		},
+	}
+
+	_, err = r.Reconcile(context.Background(), req)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	obj := &v1alpha1.Http{}
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, obj))
+	assert.False(t, conditions.IsReady(obj))
+	assert.Contains(t, conditions.GetMessage(obj, meta.ReadyCondition), "timeout of 200ms exceeded")
+	assert.Empty(t, obj.Status.LastAppliedRevision)
+}
+
+func TestHttpReconciler_ReconcileVerificationCredentials(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "test-reconcile-verification-credentials")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	content, err := os.ReadFile(filepath.Join("testdata", "content.tar.gz"))
+	require.NoError(t, err)
+
+	sum := sha256.Sum256(content)
+	checksums := []byte(hex.EncodeToString(sum[:]) + "  content.tar.gz\n")
+
+	var checksumHeaders http.Header
+	testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/SHA256SUMS" {
+			checksumHeaders = r.Header.Clone()
+			w.Write(checksums)
+			return
+		}
+		w.Write(content)
+	}))
+	defer testserver.Close()
+
+	thirdParty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checksumHeaders = r.Header.Clone()
+		w.Write(checksums)
+	}))
+	defer thirdParty.Close()
+
+	c := env.FakeKubeClient(
+		WithObjects(&v1alpha1.Http{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-http-verification-credentials",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.HttpSpec{
+				URL:              testserver.URL + "/content.tar.gz",
+				SecretRef:        &meta.LocalObjectReference{Name: "auth"},
+				HeadersSecretRef: &meta.LocalObjectReference{Name: "headers"},
+				Verify: &v1alpha1.HttpVerification{
+					ChecksumURL: thirdParty.URL + "/SHA256SUMS",
+				},
+			},
+		}, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "auth", Namespace: "default"},
+			Data:       map[string][]byte{"bearerToken": []byte("token")},
+		}, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "headers", Namespace: "default"},
+			Data:       map[string][]byte{"PRIVATE-TOKEN": []byte("secret")},
+		}))
+	s, err := storage.NewStorage(c, env.scheme, tmp, "hostname", 0, 0)
+	require.NoError(t, err)
+
+	r := &HttpReconciler{
+		Client:        c,
+		Scheme:        env.scheme,
+		Fetcher:       fetcher.NewFetcher(testserver.Client()),
+		Storage:       s,
+		EventRecorder: record.NewFakeRecorder(32),
+	}
+	req := controllerruntime.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "test-http-verification-credentials",
+			Namespace: "default",
+		},
+	}
+
+	// The credentials of the URL aren't sent to another host.
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.NotNil(t, checksumHeaders)
+	assert.Empty(t, checksumHeaders.Get("Authorization"))
+	assert.Empty(t, checksumHeaders.Get("Private-Token"))
+
+	// They're sent to the host of the URL.
+	obj := &v1alpha1.Http{}
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, obj))
+	obj.Spec.Verify.ChecksumURL = testserver.URL + "/SHA256SUMS"
+	require.NoError(t, c.Update(context.Background(), obj))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer token", checksumHeaders.Get("Authorization"))
+	assert.Equal(t, "secret", checksumHeaders.Get("Private-Token"))
+
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, obj))
+	assert.True(t, conditions.IsReady(obj))
 }
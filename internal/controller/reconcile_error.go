@@ -0,0 +1,144 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fluxcd/pkg/apis/meta"
+
+	openfluxcdv1alpha1 "github.com/openfluxcd/http-source-controller/api/v1alpha1"
+	"github.com/openfluxcd/http-source-controller/internal/fetcher"
+)
+
+// reconcileError annotates a failed reconciliation with the reason reported on
+// the Ready condition.
+type reconcileError struct {
+	// Reason is the condition reason of the failure.
+	Reason string
+	// Stalled marks failures which can't be resolved by retrying, only by a change
+	// of the object or the Secrets it references.
+	Stalled bool
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *reconcileError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *reconcileError) Unwrap() error {
+	return e.Err
+}
+
+// newReconcileError returns a retryable error with the given condition reason.
+func newReconcileError(reason string, err error) error {
+	return &reconcileError{Reason: reason, Err: err}
+}
+
+// newStalledError returns an error with the given condition reason that is not retried.
+func newStalledError(reason string, err error) error {
+	return &reconcileError{Reason: reason, Stalled: true, Err: err}
+}
+
+// reasonFor returns the condition reason of err and whether it stalls the reconciliation.
+func reasonFor(err error) (string, bool) {
+	var rerr *reconcileError
+	if errors.As(err, &rerr) {
+		return rerr.Reason, rerr.Stalled
+	}
+
+	return meta.FailedReason, false
+}
+
+// fetchError classifies the error returned by Fetcher.FetchFirst. Failures which
+// won't go away by fetching the same URL again stall the reconciliation.
+// If all locations failed, it's only stalled if all of them failed permanently.
+func fetchError(err error) error {
+	var locationsErr *fetcher.LocationsError
+	if errors.As(err, &locationsErr) {
+		var rerr *reconcileError
+		for _, locationErr := range locationsErr.Errs {
+			if errors.As(fetchError(locationErr), &rerr) && !rerr.Stalled {
+				return newReconcileError(rerr.Reason, fmt.Errorf("failed to fetch http source: %w", err))
+			}
+		}
+
+		return newStalledError(rerr.Reason, fmt.Errorf("failed to fetch http source: %w", err))
+	}
+
+	// Errors resolving the verify options have been classified by verifyOptions.
+	var verifyErr *fetcher.VerifyOptionsError
+	if errors.As(err, &verifyErr) {
+		return err
+	}
+
+	var (
+		mismatchErr  *fetcher.DigestMismatchError
+		signatureErr *fetcher.SignatureVerificationError
+		authErr      *fetcher.AuthError
+		statusErr    *fetcher.HTTPStatusError
+		sizeErr      *fetcher.SizeLimitError
+		archiveErr   *fetcher.ArchiveError
+	)
+
+	switch {
+	case errors.As(err, &mismatchErr), errors.As(err, &signatureErr):
+		return newReconcileError(openfluxcdv1alpha1.VerificationFailedReason, fmt.Errorf("failed to verify http source: %w", err))
+	case errors.As(err, &authErr):
+		return newStalledError(openfluxcdv1alpha1.AuthenticationFailedReason, fmt.Errorf("failed to fetch http source: %w", err))
+	case errors.As(err, &statusErr) && statusErr.Terminal():
+		return newStalledError(openfluxcdv1alpha1.FetchFailedReason, fmt.Errorf("failed to fetch http source: %w", err))
+	case errors.As(err, &sizeErr):
+		return newStalledError(openfluxcdv1alpha1.SizeLimitExceededReason, fmt.Errorf("failed to fetch http source: %w", err))
+	case errors.As(err, &archiveErr):
+		return newStalledError(openfluxcdv1alpha1.ExtractionFailedReason, fmt.Errorf("failed to fetch http source: %w", err))
+	default:
+		return newReconcileError(openfluxcdv1alpha1.FetchFailedReason, fmt.Errorf("failed to fetch http source: %w", err))
+	}
+}
+
+// rejectedDigest returns the digest of content that failed verification, if
+// it was fetched completely.
+func rejectedDigest(err error) string {
+	var (
+		mismatchErr  *fetcher.DigestMismatchError
+		signatureErr *fetcher.SignatureVerificationError
+	)
+
+	switch {
+	case errors.As(err, &mismatchErr):
+		return mismatchErr.Digest
+	case errors.As(err, &signatureErr):
+		return signatureErr.Digest
+	default:
+		return ""
+	}
+}
+
+// retryAfter returns the wait the server asked for with a Retry-After header
+// in the response that failed the fetch.
+func retryAfter(err error) time.Duration {
+	var statusErr *fetcher.HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+
+	return 0
+}
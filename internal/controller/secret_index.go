@@ -0,0 +1,63 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	openfluxcdv1alpha1 "github.com/openfluxcd/http-source-controller/api/v1alpha1"
+)
+
+// secretRefsIndexKey indexes Http objects by the names of all Secrets they reference.
+const secretRefsIndexKey = ".metadata.secretRefs"
+
+// indexSecretRefs returns the names of all Secrets referenced by an Http object.
+func indexSecretRefs(obj client.Object) []string {
+	src, ok := obj.(*openfluxcdv1alpha1.Http)
+	if !ok {
+		return nil
+	}
+
+	var names []string
+	if src.Spec.SecretRef != nil {
+		names = append(names, src.Spec.SecretRef.Name)
+	}
+
+	if src.Spec.ProxySecretRef != nil {
+		names = append(names, src.Spec.ProxySecretRef.Name)
+	}
+
+	if src.Spec.HeadersSecretRef != nil {
+		names = append(names, src.Spec.HeadersSecretRef.Name)
+	}
+
+	for _, mirror := range src.Spec.Mirrors {
+		if mirror.SecretRef != nil {
+			names = append(names, mirror.SecretRef.Name)
+		}
+	}
+
+	if src.Spec.CertSecretRef != nil {
+		names = append(names, src.Spec.CertSecretRef.Name)
+	}
+
+	if src.Spec.Verify != nil && src.Spec.Verify.SecretRef != nil {
+		names = append(names, src.Spec.Verify.SecretRef.Name)
+	}
+
+	return names
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+
+	openfluxcdv1alpha1 "github.com/openfluxcd/http-source-controller/api/v1alpha1"
+	"github.com/openfluxcd/http-source-controller/internal/fetcher"
+)
+
+// verifyOptionsFunc resolves the verify options of the object at most once,
+// although FetchFirst asks for them at every location serving content.
+func (r *HttpReconciler) verifyOptionsFunc(obj *openfluxcdv1alpha1.Http, connOpts, credentialOpts []fetcher.FetchOptionsFn) fetcher.VerifyOptionsFunc {
+	var (
+		resolved bool
+		opts     []fetcher.FetchOptionsFn
+		err      error
+	)
+
+	return func(ctx context.Context) ([]fetcher.FetchOptionsFn, error) {
+		if !resolved {
+			opts, err = r.verifyOptions(ctx, obj, connOpts, credentialOpts)
+			resolved = true
+		}
+
+		return opts, err
+	}
+}
+
+// verifyOptions returns the digests and the signature the fetched content has
+// to match. Checksum and signature files are fetched with the same connection
+// options as the URL, and with its credentials if they're on the same host.
+func (r *HttpReconciler) verifyOptions(ctx context.Context, obj *openfluxcdv1alpha1.Http, connOpts, credentialOpts []fetcher.FetchOptionsFn) ([]fetcher.FetchOptionsFn, error) {
+	if obj.Spec.Verify == nil {
+		return nil, nil
+	}
+
+	var opts []fetcher.FetchOptionsFn
+	if obj.Spec.Verify.Digest != "" {
+		opts = append(opts, fetcher.WithDigest(obj.Spec.Verify.Digest))
+	}
+
+	if obj.Spec.Verify.ChecksumURL != "" {
+		name, err := urlFileName(obj.Spec.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		checksumOpts := verificationFileOptions(obj, obj.Spec.Verify.ChecksumURL, connOpts, credentialOpts)
+
+		digest, err := r.Fetcher.FetchChecksum(ctx, obj.Spec.Verify.ChecksumURL, name, checksumOpts...)
+		if err != nil {
+			return nil, newReconcileError(openfluxcdv1alpha1.VerificationFailedReason,
+				fmt.Errorf("failed to get checksum from '%s': %w", obj.Spec.Verify.ChecksumURL, err))
+		}
+
+		opts = append(opts, fetcher.WithDigest(digest))
+	}
+
+	if obj.Spec.Verify.Provider != "" {
+		signatureOpt, err := r.signatureOption(ctx, obj, connOpts, credentialOpts)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, signatureOpt)
+	}
+
+	return opts, nil
+}
+
+// signatureOption fetches the signature of the content and loads the public
+// keys it's verified with.
+func (r *HttpReconciler) signatureOption(ctx context.Context, obj *openfluxcdv1alpha1.Http, connOpts, credentialOpts []fetcher.FetchOptionsFn) (fetcher.FetchOptionsFn, error) {
+	verify := obj.Spec.Verify
+	if verify.Provider != openfluxcdv1alpha1.CosignProvider {
+		return nil, newStalledError(openfluxcdv1alpha1.VerificationFailedReason,
+			fmt.Errorf("unsupported verification provider '%s'", verify.Provider))
+	}
+
+	if verify.SecretRef == nil {
+		return nil, newStalledError(openfluxcdv1alpha1.VerificationFailedReason,
+			errors.New("spec.verify.secretRef is required for keyed cosign verification"))
+	}
+
+	if (verify.SignatureURL == "") == (verify.BundleURL == "") {
+		return nil, newStalledError(openfluxcdv1alpha1.VerificationFailedReason,
+			errors.New("exactly one of spec.verify.signatureURL and spec.verify.bundleURL must be set"))
+	}
+
+	verifiers, err := r.publicKeyVerifiers(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var sig []byte
+	if verify.SignatureURL != "" {
+		opts := verificationFileOptions(obj, verify.SignatureURL, connOpts, credentialOpts)
+		sig, err = r.Fetcher.FetchSignature(ctx, verify.SignatureURL, opts...)
+	} else {
+		opts := verificationFileOptions(obj, verify.BundleURL, connOpts, credentialOpts)
+		sig, err = r.Fetcher.FetchSignatureBundle(ctx, verify.BundleURL, opts...)
+	}
+
+	if err != nil {
+		return nil, newReconcileError(openfluxcdv1alpha1.VerificationFailedReason, err)
+	}
+
+	return fetcher.WithSignature(sig, verifiers...), nil
+}
+
+// verificationFileOptions returns the options for fetching the verification file
+// at rawURL. The credentials of the URL are only added if rawURL has the same
+// scheme and host, so they're never sent to third-party hosts.
+func verificationFileOptions(obj *openfluxcdv1alpha1.Http, rawURL string, connOpts, credentialOpts []fetcher.FetchOptionsFn) []fetcher.FetchOptionsFn {
+	if !sameOrigin(obj.Spec.URL, rawURL) {
+		return connOpts
+	}
+
+	return append(slices.Clone(connOpts), credentialOpts...)
+}
+
+// sameOrigin reports whether both URLs have the same scheme and host.
+func sameOrigin(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+
+	return ua.Scheme == ub.Scheme && ua.Host == ub.Host
+}
+
+// publicKeyVerifiers loads a verifier for every public key in the Secret
+// referenced by spec.verify.secretRef.
+func (r *HttpReconciler) publicKeyVerifiers(ctx context.Context, obj *openfluxcdv1alpha1.Http) ([]signature.Verifier, error) {
+	secret, err := r.getSecret(ctx, obj.Namespace, obj.Spec.Verify.SecretRef.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(secret.Data))
+	for key := range secret.Data {
+		if strings.HasSuffix(key, ".pub") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	if len(keys) == 0 {
+		return nil, newStalledError(openfluxcdv1alpha1.VerificationFailedReason,
+			fmt.Errorf("no public keys ending with '.pub' found in secret '%s'", secret.Name))
+	}
+
+	verifiers := make([]signature.Verifier, 0, len(keys))
+	for _, key := range keys {
+		publicKey, err := cryptoutils.UnmarshalPEMToPublicKey(secret.Data[key])
+		if err != nil {
+			return nil, newStalledError(openfluxcdv1alpha1.VerificationFailedReason,
+				fmt.Errorf("failed to parse public key '%s' in secret '%s': %w", key, secret.Name, err))
+		}
+
+		verifier, err := signature.LoadVerifier(publicKey, crypto.SHA256)
+		if err != nil {
+			return nil, newStalledError(openfluxcdv1alpha1.VerificationFailedReason,
+				fmt.Errorf("failed to load public key '%s' in secret '%s': %w", key, secret.Name, err))
+		}
+
+		verifiers = append(verifiers, verifier)
+	}
+
+	return verifiers, nil
+}
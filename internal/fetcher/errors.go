@@ -0,0 +1,100 @@
+package fetcher
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HTTPStatusError is returned for responses with a status code other than 2xx.
+type HTTPStatusError struct {
+	// URL is the requested URL without credentials.
+	URL string
+	// StatusCode is the status code of the response.
+	StatusCode int
+	// RetryAfter is the wait the server asked for with a Retry-After header.
+	RetryAfter time.Duration
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("failed to fetch url content with status code %d", e.StatusCode)
+}
+
+// Terminal reports whether the request failed permanently, so that retrying it
+// without changing the request is pointless. That's the case for all client
+// errors except timeouts and rate limits.
+func (e *HTTPStatusError) Terminal() bool {
+	return e.StatusCode >= http.StatusBadRequest && e.StatusCode < http.StatusInternalServerError &&
+		!retryableStatusCodes[e.StatusCode]
+}
+
+// AuthError is returned when the server rejected the credentials of a request.
+type AuthError struct {
+	Err error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("authentication failed: %s", e.Err)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// ArchiveError is returned when the fetched archive can't be detected or extracted.
+type ArchiveError struct {
+	// Format is the archive format, if it's known.
+	Format Format
+	Err    error
+}
+
+func (e *ArchiveError) Error() string {
+	if e.Format == "" {
+		return e.Err.Error()
+	}
+
+	return fmt.Sprintf("failed to extract %s archive: %s", e.Format, e.Err)
+}
+
+func (e *ArchiveError) Unwrap() error {
+	return e.Err
+}
+
+// SizeLimitError is returned when fetched or extracted content exceeds a limit.
+type SizeLimitError struct {
+	// Limit names the exceeded limit, e.g. 'max extracted size'.
+	Limit string
+	// Max is the value of the limit in Unit, which is empty for counts.
+	Max  int64
+	Unit string
+	// Name is the archive entry exceeding the limit, if any.
+	Name string
+}
+
+func (e *SizeLimitError) Error() string {
+	subject := "content"
+	if e.Name != "" {
+		subject = fmt.Sprintf("archive entry %q", e.Name)
+	}
+
+	msg := fmt.Sprintf("%s exceeds the %s of %d", subject, e.Limit, e.Max)
+	if e.Unit != "" {
+		msg += " " + e.Unit
+	}
+
+	return msg
+}
+
+// statusError returns the error for the unsuccessful response.
+func statusError(resp *http.Response) error {
+	err := &HTTPStatusError{URL: redactURL(resp.Request.URL), StatusCode: resp.StatusCode}
+	if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+		err.RetryAfter = retryAfter
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return &AuthError{Err: err}
+	}
+
+	return err
+}
@@ -0,0 +1,456 @@
+package fetcher
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+
+	"github.com/openfluxcd/http-source-controller/internal/metrics"
+)
+
+// Format is the archive format of fetched content.
+type Format string
+
+const (
+	FormatTarGzip  Format = "tar.gz"
+	FormatTar      Format = "tar"
+	FormatTarXz    Format = "tar.xz"
+	FormatTarZstd  Format = "tar.zst"
+	FormatTarBzip2 Format = "tar.bz2"
+	FormatZip      Format = "zip"
+)
+
+// ExtractOptions configures the extraction of an archive.
+type ExtractOptions struct {
+	// MaxSize is the maximum total size of the extracted files in bytes.
+	// A negative value disables the check.
+	MaxSize int64
+
+	// MaxFileCount is the maximum number of extracted files and directories.
+	// A negative value disables the check.
+	MaxFileCount int64
+
+	// StagingDir is the directory for buffering archives which can't be
+	// streamed. The default temporary directory is used when empty.
+	StagingDir string
+
+	// StripComponents is the number of leading path elements removed from
+	// the names of the entries. Entries with fewer elements are skipped.
+	StripComponents int
+
+	// Path is the directory of the archive, after stripping components, which
+	// is extracted as the root. Entries outside of it are skipped.
+	Path string
+}
+
+// Extractor extracts the archive read from r through the ArchiveWriter, which
+// rejects entries escaping the target directory and enforces the limits.
+type Extractor func(r io.Reader, w *ArchiveWriter) error
+
+var (
+	extractorsMu sync.RWMutex
+	extractors   = map[Format]Extractor{
+		FormatTarGzip: decompressingUntar(func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		}),
+		FormatTar: untar,
+		FormatTarXz: decompressingUntar(func(r io.Reader) (io.Reader, error) {
+			return xz.NewReader(r)
+		}),
+		FormatTarZstd: decompressingUntar(func(r io.Reader) (io.Reader, error) {
+			zr, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+
+			return zr.IOReadCloser(), nil
+		}),
+		FormatTarBzip2: decompressingUntar(func(r io.Reader) (io.Reader, error) {
+			return bzip2.NewReader(r), nil
+		}),
+		FormatZip: unzip,
+	}
+)
+
+// RegisterExtractor registers the extractor for the given format, replacing
+// any extractor registered for it before.
+func RegisterExtractor(format Format, extractor Extractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+
+	extractors[format] = extractor
+}
+
+// ExtractorFor returns the extractor registered for the given format.
+func ExtractorFor(format Format) (Extractor, error) {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+
+	extractor, ok := extractors[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported archive format '%s'", format)
+	}
+
+	return extractor, nil
+}
+
+// magicHeaderSize is the number of bytes needed to detect all formats by content.
+const magicHeaderSize = 262
+
+var magics = []struct {
+	format Format
+	offset int
+	magic  []byte
+}{
+	{FormatTarGzip, 0, []byte{0x1f, 0x8b}},
+	{FormatTarXz, 0, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{FormatTarZstd, 0, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{FormatTarBzip2, 0, []byte("BZh")},
+	{FormatZip, 0, []byte("PK\x03\x04")},
+	{FormatZip, 0, []byte("PK\x05\x06")},
+	{FormatTar, 257, []byte("ustar")},
+}
+
+var contentTypes = map[string]Format{
+	"application/gzip":    FormatTarGzip,
+	"application/x-gzip":  FormatTarGzip,
+	"application/x-tar":   FormatTar,
+	"application/x-xz":    FormatTarXz,
+	"application/zstd":    FormatTarZstd,
+	"application/x-bzip2": FormatTarBzip2,
+	"application/zip":     FormatZip,
+}
+
+// DetectFormat detects the archive format from the magic bytes at the start of
+// the content and falls back to the given Content-Type.
+func DetectFormat(header []byte, contentType string) (Format, error) {
+	for _, m := range magics {
+		if len(header) >= m.offset+len(m.magic) && bytes.Equal(header[m.offset:m.offset+len(m.magic)], m.magic) {
+			return m.format, nil
+		}
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if format, ok := contentTypes[mediaType]; ok {
+			return format, nil
+		}
+	}
+
+	return "", fmt.Errorf("unable to detect archive format with content type '%s'", contentType)
+}
+
+// extract detects the format of the archive read from r unless one is given
+// and extracts it into dir.
+func extract(r io.Reader, dir string, format Format, contentType string, opts ExtractOptions) error {
+	br := bufio.NewReaderSize(r, magicHeaderSize)
+
+	if format == "" {
+		// Peek returns fewer bytes with an error for short content, which is fine for detection.
+		header, _ := br.Peek(magicHeaderSize)
+
+		detected, err := DetectFormat(header, contentType)
+		if err != nil {
+			return &ArchiveError{Err: err}
+		}
+
+		format = detected
+	}
+
+	extractor, err := ExtractorFor(format)
+	if err != nil {
+		return &ArchiveError{Err: err}
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.ExtractionDuration.WithLabelValues(string(format)).Observe(time.Since(start).Seconds())
+	}()
+
+	w := NewArchiveWriter(dir, opts)
+	if err := extractor(br, w); err != nil {
+		return &ArchiveError{Format: format, Err: err}
+	}
+
+	if opts.Path != "" && !w.foundPath {
+		return &ArchiveError{Format: format, Err: fmt.Errorf("path '%s' not found in archive", opts.Path)}
+	}
+
+	return nil
+}
+
+// validatePath checks that the path to extract stays inside the archive.
+func validatePath(p string) error {
+	if p != "" && !filepath.IsLocal(filepath.FromSlash(p)) {
+		return &ArchiveError{Err: fmt.Errorf("invalid path '%s'", p)}
+	}
+
+	return nil
+}
+
+// decompressingUntar returns an Extractor which untars the output of the decompressor.
+func decompressingUntar(decompress func(io.Reader) (io.Reader, error)) Extractor {
+	return func(r io.Reader, w *ArchiveWriter) error {
+		dr, err := decompress(r)
+		if err != nil {
+			return fmt.Errorf("failed to create decompressor: %w", err)
+		}
+
+		if closer, ok := dr.(io.Closer); ok {
+			defer closer.Close()
+		}
+
+		return untar(dr, w)
+	}
+}
+
+// untar writes the uncompressed tar stream read from r.
+// Symlinks and hardlinks are rejected.
+func untar(r io.Reader, w *ArchiveWriter) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("tar error: %w", err)
+		}
+
+		// The type is taken from the type flag, since FileInfo reports links as regular files.
+		switch header.Typeflag {
+		case tar.TypeReg:
+			if err := w.WriteFile(header.Name, header.FileInfo().Mode().Perm(), tr); err != nil {
+				return err
+			}
+		case tar.TypeDir:
+			if err := w.MakeDir(header.Name); err != nil {
+				return err
+			}
+		case tar.TypeXGlobalHeader:
+			// PAX global headers, e.g. written by git archive, carry no content.
+		case tar.TypeSymlink:
+			return fmt.Errorf("tar file entry %s is a symlink, which is not allowed in this context", header.Name)
+		case tar.TypeLink:
+			return fmt.Errorf("tar file entry %s is a hardlink, which is not allowed in this context", header.Name)
+		default:
+			return fmt.Errorf("tar file entry %s contained unsupported file type %q", header.Name, header.Typeflag)
+		}
+	}
+}
+
+// unzip writes the zip archive read from r.
+// Zip archives can't be streamed, so the content is buffered to a temporary file first.
+func unzip(r io.Reader, w *ArchiveWriter) error {
+	file, err := os.CreateTemp(w.opts.StagingDir, "fetch-*.zip")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	size, err := io.Copy(file, r)
+	if err != nil {
+		return fmt.Errorf("failed to buffer zip archive: %w", err)
+	}
+
+	zr, err := zip.NewReader(file, size)
+	if err != nil {
+		return fmt.Errorf("zip error: %w", err)
+	}
+
+	for _, f := range zr.File {
+		mode := f.Mode()
+		switch {
+		case mode.IsRegular():
+			if err := unzipFile(w, f); err != nil {
+				return err
+			}
+		case mode.IsDir():
+			if err := w.MakeDir(f.Name); err != nil {
+				return err
+			}
+		case mode&os.ModeSymlink != 0:
+			return fmt.Errorf("zip file entry %s is a symlink, which is not allowed in this context", f.Name)
+		default:
+			return fmt.Errorf("zip file entry %s contained unsupported file type %v", f.Name, mode)
+		}
+	}
+
+	return nil
+}
+
+func unzipFile(w *ArchiveWriter, f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open zip file entry %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	return w.WriteFile(f.Name, f.Mode().Perm(), rc)
+}
+
+// ArchiveWriter writes archive entries into a directory.
+type ArchiveWriter struct {
+	dir       string
+	opts      ExtractOptions
+	written   int64
+	entries   int64
+	foundPath bool
+}
+
+// NewArchiveWriter returns an ArchiveWriter writing into dir.
+func NewArchiveWriter(dir string, opts ExtractOptions) *ArchiveWriter {
+	return &ArchiveWriter{
+		dir:  dir,
+		opts: opts,
+	}
+}
+
+// MakeDir creates the directory entry name.
+func (w *ArchiveWriter) MakeDir(name string) error {
+	path, ok, err := w.securePath(name, true)
+	if err != nil || !ok {
+		return err
+	}
+
+	if err := w.countEntry(); err != nil {
+		return err
+	}
+
+	return os.MkdirAll(path, 0o750)
+}
+
+// WriteFile writes the content of the file entry name read from r.
+func (w *ArchiveWriter) WriteFile(name string, perm os.FileMode, r io.Reader) error {
+	path, ok, err := w.securePath(name, false)
+	if err != nil || !ok {
+		return err
+	}
+
+	if err := w.countEntry(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	if w.opts.MaxSize >= 0 {
+		// Read one byte more than allowed to detect exceeding the limit.
+		r = io.LimitReader(r, w.opts.MaxSize-w.written+1)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	n, err := io.Copy(file, r)
+	w.written += n
+	if err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("error writing to %s: %w", path, err)
+	}
+
+	if w.opts.MaxSize >= 0 && w.written > w.opts.MaxSize {
+		_ = file.Close()
+
+		return &SizeLimitError{Limit: "max extracted size", Max: w.opts.MaxSize, Unit: "bytes", Name: name}
+	}
+
+	return file.Close()
+}
+
+// countEntry counts a written entry against the max file count.
+func (w *ArchiveWriter) countEntry() error {
+	w.entries++
+	if w.opts.MaxFileCount >= 0 && w.entries > w.opts.MaxFileCount {
+		return &SizeLimitError{Limit: "max file count", Max: w.opts.MaxFileCount}
+	}
+
+	return nil
+}
+
+// securePath returns the path of the archive entry name inside the directory,
+// or false if the entry is skipped by StripComponents or Path.
+// Names which are absolute or contain a '..' element are rejected.
+func (w *ArchiveWriter) securePath(name string, dir bool) (string, bool, error) {
+	if name == "" || strings.Contains(name, `\`) || strings.HasPrefix(name, "/") || slices.Contains(strings.Split(name, "/"), "..") {
+		return "", false, fmt.Errorf("archive contained invalid name %q", name)
+	}
+
+	name, ok, err := w.rebase(name, dir)
+	if err != nil || !ok {
+		return "", false, err
+	}
+
+	path, err := securejoin.SecureJoin(w.dir, filepath.FromSlash(name))
+
+	return path, true, err
+}
+
+// rebase strips the leading components of name and makes it relative to Path.
+// Path must be a directory, a file entry at Path is rejected.
+func (w *ArchiveWriter) rebase(name string, dir bool) (string, bool, error) {
+	if w.opts.StripComponents == 0 && w.opts.Path == "" {
+		return name, true, nil
+	}
+
+	elems := strings.Split(path.Clean(name), "/")
+	if elems[0] == "." {
+		elems = elems[1:]
+	}
+
+	if len(elems) <= w.opts.StripComponents {
+		return "", false, nil
+	}
+
+	name = strings.Join(elems[w.opts.StripComponents:], "/")
+	if w.opts.Path == "" {
+		return name, true, nil
+	}
+
+	root := path.Clean(w.opts.Path)
+	if root == "." {
+		w.foundPath = true
+
+		return name, true, nil
+	}
+
+	if name == root {
+		if !dir {
+			return "", false, fmt.Errorf("path '%s' is not a directory", w.opts.Path)
+		}
+
+		w.foundPath = true
+
+		return "", false, nil
+	}
+
+	if !strings.HasPrefix(name, root+"/") {
+		return "", false, nil
+	}
+
+	w.foundPath = true
+
+	return strings.TrimPrefix(name, root+"/"), true, nil
+}
@@ -0,0 +1,289 @@
+package fetcher
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+func tarball(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0o600,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	return buf.Bytes()
+}
+
+func compress(t *testing.T, content []byte, newWriter func(io.Writer) (io.WriteCloser, error)) []byte {
+	buf := &bytes.Buffer{}
+	w, err := newWriter(buf)
+	require.NoError(t, err)
+	_, err = w.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func zipball(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	return buf.Bytes()
+}
+
+func TestExtract(t *testing.T) {
+	files := map[string]string{
+		"README.md":          "readme",
+		"manifests/app.yaml": "kind: ConfigMap",
+	}
+
+	tests := []struct {
+		name        string
+		content     func(t *testing.T) []byte
+		format      Format
+		contentType string
+		opts        ExtractOptions
+		expected    map[string]string
+		assertErr   func(t *testing.T, err error)
+	}{
+		{
+			name: "tar.gz",
+			content: func(t *testing.T) []byte {
+				return compress(t, tarball(t, files), func(w io.Writer) (io.WriteCloser, error) {
+					return gzip.NewWriter(w), nil
+				})
+			},
+		},
+		{
+			name: "tar",
+			content: func(t *testing.T) []byte {
+				return tarball(t, files)
+			},
+		},
+		{
+			name: "tar.xz",
+			content: func(t *testing.T) []byte {
+				return compress(t, tarball(t, files), func(w io.Writer) (io.WriteCloser, error) {
+					return xz.NewWriter(w)
+				})
+			},
+		},
+		{
+			name: "tar.zst",
+			content: func(t *testing.T) []byte {
+				return compress(t, tarball(t, files), func(w io.Writer) (io.WriteCloser, error) {
+					return zstd.NewWriter(w)
+				})
+			},
+		},
+		{
+			name: "tar.bz2",
+			content: func(t *testing.T) []byte {
+				// There's no bzip2 writer in the standard library, the archive contains the same files.
+				content, err := os.ReadFile(filepath.Join("testdata", "content.tar.bz2"))
+				require.NoError(t, err)
+
+				return content
+			},
+		},
+		{
+			name: "zip",
+			content: func(t *testing.T) []byte {
+				return zipball(t, files)
+			},
+		},
+		{
+			name: "explicit format",
+			content: func(t *testing.T) []byte {
+				return zipball(t, files)
+			},
+			format: FormatZip,
+		},
+		{
+			name: "unknown content",
+			content: func(t *testing.T) []byte {
+				return []byte("plain text")
+			},
+			contentType: "text/plain",
+			assertErr: func(t *testing.T, err error) {
+				require.ErrorContains(t, err, "unable to detect archive format")
+			},
+		},
+		{
+			name: "path traversal",
+			content: func(t *testing.T) []byte {
+				return tarball(t, map[string]string{"../escape": "content"})
+			},
+			assertErr: func(t *testing.T, err error) {
+				require.ErrorContains(t, err, "invalid name")
+			},
+		},
+		{
+			name: "dots in name",
+			content: func(t *testing.T) []byte {
+				return tarball(t, map[string]string{"a../b": "content", "..c": "content"})
+			},
+			expected: map[string]string{"a../b": "content", "..c": "content"},
+		},
+		{
+			name: "hardlink",
+			content: func(t *testing.T) []byte {
+				buf := bytes.NewBuffer(tarball(t, map[string]string{"a.txt": "content"}))
+				// Drop the end-of-archive marker to append the link.
+				buf.Truncate(buf.Len() - 1024)
+				tw := tar.NewWriter(buf)
+				require.NoError(t, tw.WriteHeader(&tar.Header{
+					Name:     "b.txt",
+					Linkname: "a.txt",
+					Mode:     0o600,
+					Typeflag: tar.TypeLink,
+				}))
+				require.NoError(t, tw.Close())
+
+				return buf.Bytes()
+			},
+			assertErr: func(t *testing.T, err error) {
+				var archiveErr *ArchiveError
+				require.ErrorAs(t, err, &archiveErr)
+				require.ErrorContains(t, err, "tar file entry b.txt is a hardlink")
+			},
+		},
+		{
+			name: "strip components",
+			content: func(t *testing.T) []byte {
+				return tarball(t, map[string]string{
+					"repo-abc/README.md":          "readme",
+					"repo-abc/manifests/app.yaml": "kind: ConfigMap",
+				})
+			},
+			opts: ExtractOptions{StripComponents: 1},
+		},
+		{
+			name: "path",
+			content: func(t *testing.T) []byte {
+				return zipball(t, map[string]string{
+					"repo-abc/README.md":          "readme",
+					"repo-abc/manifests/app.yaml": "kind: ConfigMap",
+				})
+			},
+			opts:     ExtractOptions{StripComponents: 1, Path: "manifests/"},
+			expected: map[string]string{"app.yaml": "kind: ConfigMap"},
+		},
+		{
+			name: "path not found",
+			content: func(t *testing.T) []byte {
+				return tarball(t, files)
+			},
+			opts: ExtractOptions{Path: "charts"},
+			assertErr: func(t *testing.T, err error) {
+				var archiveErr *ArchiveError
+				require.ErrorAs(t, err, &archiveErr)
+				require.ErrorContains(t, err, "path 'charts' not found in archive")
+			},
+		},
+		{
+			name: "path of a file",
+			content: func(t *testing.T) []byte {
+				return tarball(t, map[string]string{
+					"repo-abc/README.md":          "readme",
+					"repo-abc/manifests/app.yaml": "kind: ConfigMap",
+				})
+			},
+			opts: ExtractOptions{StripComponents: 1, Path: "README.md"},
+			assertErr: func(t *testing.T, err error) {
+				var archiveErr *ArchiveError
+				require.ErrorAs(t, err, &archiveErr)
+				require.ErrorContains(t, err, "path 'README.md' is not a directory")
+			},
+		},
+		{
+			name: "max file count",
+			content: func(t *testing.T) []byte {
+				return tarball(t, files)
+			},
+			opts: ExtractOptions{MaxFileCount: 1},
+			assertErr: func(t *testing.T, err error) {
+				var sizeErr *SizeLimitError
+				require.ErrorAs(t, err, &sizeErr)
+				require.ErrorContains(t, err, "content exceeds the max file count of 1")
+			},
+		},
+		{
+			name: "max size",
+			content: func(t *testing.T) []byte {
+				return zipball(t, files)
+			},
+			opts: ExtractOptions{MaxSize: 3},
+			assertErr: func(t *testing.T, err error) {
+				require.ErrorContains(t, err, "exceeds the max extracted size")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			opts := tt.opts
+			if opts.MaxSize == 0 {
+				opts.MaxSize = DefaultMaxExtractedSize
+			}
+			if opts.MaxFileCount == 0 {
+				opts.MaxFileCount = DefaultMaxFileCount
+			}
+
+			err := extract(bytes.NewReader(tt.content(t)), dir, tt.format, tt.contentType, opts)
+			if tt.assertErr != nil {
+				tt.assertErr(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			expected := tt.expected
+			if expected == nil {
+				expected = files
+			}
+
+			var extracted []string
+			require.NoError(t, filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					extracted = append(extracted, path)
+				}
+				return err
+			}))
+			assert.Len(t, extracted, len(expected))
+
+			for name, content := range expected {
+				got, err := os.ReadFile(filepath.Join(dir, name))
+				require.NoError(t, err)
+				assert.Equal(t, content, string(got))
+			}
+		})
+	}
+}
@@ -3,25 +3,46 @@ package fetcher
 import (
 	"context"
 	"crypto/sha256"
+	"crypto/tls"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
 
-	"github.com/fluxcd/pkg/tar"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/http/httpproxy"
+
+	"github.com/openfluxcd/http-source-controller/internal/metrics"
+	"github.com/openfluxcd/http-source-controller/internal/tracing"
 )
 
+// DefaultTimeout is the total time of a URL fetch when no timeout is set.
+const DefaultTimeout = 5 * time.Minute
+
 // Fetcher wraps an HTTP client.
 type Fetcher struct {
 	client *http.Client
+
+	mu         sync.Mutex
+	transports map[string]cachedTransport
 }
 
 // NewFetcher constructs a new client wrapper with a given client.
 func NewFetcher(client *http.Client) *Fetcher {
 	return &Fetcher{
-		client: client,
+		client:     client,
+		transports: map[string]cachedTransport{},
 	}
 }
 
@@ -48,35 +69,224 @@ func WithToken(token string) FetchOptionsFn {
 	}
 }
 
+// WithHeaders adds the headers to the requests of the URL fetch. It can be
+// given multiple times. Credentials set by WithUsername, WithPassword or
+// WithToken take precedence over an Authorization header.
+func WithHeaders(headers map[string]string) FetchOptionsFn {
+	return func(opt *FetchOptions) {
+		if opt.headers == nil {
+			opt.headers = http.Header{}
+		}
+
+		for name, value := range headers {
+			opt.headers.Set(name, value)
+		}
+	}
+}
+
+// WithSecretHeaders adds the headers to the requests of the URL fetch like
+// WithHeaders, but drops them when a redirect leads to another scheme or host.
+func WithSecretHeaders(headers map[string]string) FetchOptionsFn {
+	return func(opt *FetchOptions) {
+		WithHeaders(headers)(opt)
+
+		for name := range headers {
+			opt.secretHeaders = append(opt.secretHeaders, name)
+		}
+	}
+}
+
+// WithTLSConfig provides an optional TLS configuration to the URL fetch.
+// A dedicated transport is built for the configuration instead of using the shared client.
+func WithTLSConfig(config *tls.Config) FetchOptionsFn {
+	return func(opt *FetchOptions) {
+		opt.tlsConfig = config
+	}
+}
+
+// WithProxy sends the requests of the URL fetch through the proxy at
+// proxyURL, which may contain the credentials of the proxy. Hosts matching
+// noProxy, a comma-separated list in the format of the NO_PROXY environment
+// variable, are connected to directly. A dedicated transport is built for the
+// proxy instead of using the shared client.
+func WithProxy(proxyURL *url.URL, noProxy string) FetchOptionsFn {
+	return func(opt *FetchOptions) {
+		opt.proxy = &httpproxy.Config{
+			HTTPProxy:  proxyURL.String(),
+			HTTPSProxy: proxyURL.String(),
+			NoProxy:    noProxy,
+		}
+	}
+}
+
+// WithTransportCacheKey caches the dedicated transport of the URL fetch under key.
+// The transport is rebuilt whenever version changes, and removed once the
+// fetch doesn't require a dedicated transport anymore.
+func WithTransportCacheKey(key, version string) FetchOptionsFn {
+	return func(opt *FetchOptions) {
+		opt.transportKey = key
+		opt.transportVersion = version
+	}
+}
+
+// WithFormat provides the archive format of the URL content.
+// The format is detected from the content when omitted.
+func WithFormat(format Format) FetchOptionsFn {
+	return func(opt *FetchOptions) {
+		opt.format = format
+	}
+}
+
+// WithFile stores the URL content as a single file named filename
+// instead of extracting it.
+func WithFile(filename string) FetchOptionsFn {
+	return func(opt *FetchOptions) {
+		opt.filename = filename
+	}
+}
+
+// WithStripComponents removes the given number of leading path elements from
+// the names of the extracted entries, such as the top-level directory of
+// source tarballs.
+func WithStripComponents(n int) FetchOptionsFn {
+	return func(opt *FetchOptions) {
+		opt.stripComponents = n
+	}
+}
+
+// WithPath extracts only the given directory of the archive as the root of
+// the content. The directory must exist in the archive.
+func WithPath(path string) FetchOptionsFn {
+	return func(opt *FetchOptions) {
+		opt.path = path
+	}
+}
+
+// WithStagingDir sets the directory for intermediate files, such as zip
+// archives buffered before extraction, which must not be published. The
+// default temporary directory is used when omitted.
+func WithStagingDir(dir string) FetchOptionsFn {
+	return func(opt *FetchOptions) {
+		opt.stagingDir = dir
+	}
+}
+
+// WithTimeout limits the total time of the URL fetch, including retries and
+// the transfer of the content. DefaultTimeout is used unless it's positive.
+func WithTimeout(timeout time.Duration) FetchOptionsFn {
+	return func(opt *FetchOptions) {
+		opt.timeout = timeout
+	}
+}
+
+// WithETag sends the ETag of the previously fetched content as If-None-Match.
+func WithETag(etag string) FetchOptionsFn {
+	return func(opt *FetchOptions) {
+		opt.etag = etag
+	}
+}
+
+// WithLastModified sends the Last-Modified time of the previously fetched
+// content as If-Modified-Since.
+func WithLastModified(lastModified string) FetchOptionsFn {
+	return func(opt *FetchOptions) {
+		opt.lastModified = lastModified
+	}
+}
+
 type FetchOptions struct {
 	username string
 	password string
 	token    string
+	headers  http.Header
+	format   Format
+	filename string
+	digests  []string
+
+	secretHeaders []string
+
+	stripComponents int
+	path            string
+	stagingDir      string
+
+	etag         string
+	lastModified string
+	retryPolicy  RetryPolicy
+	limits       Limits
+	timeout      time.Duration
+
+	signature     []byte
+	verifiers     []signature.Verifier
+	resolveVerify VerifyOptionsFunc
+
+	tlsConfig        *tls.Config
+	proxy            *httpproxy.Config
+	transportKey     string
+	transportVersion string
+}
+
+// Result describes the content fetched by Fetch.
+type Result struct {
+	// Digest is the hex encoded SHA256 digest of the fetched content.
+	Digest string
+
+	// ETag and LastModified are the validators of the response, which are
+	// passed to the next Fetch with WithETag and WithLastModified.
+	ETag         string
+	LastModified string
+
+	// URL is the location the content has been fetched from by FetchFirst.
+	URL string
+
+	// NotModified is true if the server responded with 304 Not Modified.
+	// Nothing has been fetched then and Digest is empty.
+	NotModified bool
 }
 
 // Fetch constructs a request and does a client.Do with it.
-func (f *Fetcher) Fetch(ctx context.Context, url, dir string, opts ...FetchOptionsFn) (string, error) {
+func (f *Fetcher) Fetch(ctx context.Context, url, dir string, opts ...FetchOptionsFn) (_ *Result, retErr error) {
+	ctx, span := tracing.Tracer().Start(ctx, "Fetch")
+	defer func() {
+		tracing.End(span, retErr)
+	}()
+
 	opt := &FetchOptions{}
 	for _, fn := range opts {
 		fn(opt)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	ctx, cancel := opt.withTimeout(ctx)
+	defer cancel()
+	defer func() {
+		retErr = opt.timeoutError(ctx, retErr)
+	}()
+
+	if opt.filename != "" && (filepath.Base(opt.filename) != opt.filename || opt.filename == "." || opt.filename == "..") {
+		return nil, fmt.Errorf("invalid filename '%s'", opt.filename)
+	}
+
+	if err := validatePath(opt.path); err != nil {
+		return nil, err
+	}
+
+	req, err := f.newRequest(ctx, url, opt)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate request for url '%s': %w", url, err)
+		return nil, err
 	}
 
-	if opt.username != "" && opt.password != "" {
-		req.SetBasicAuth(opt.username, opt.password)
+	if opt.etag != "" {
+		req.Header.Set("If-None-Match", opt.etag)
 	}
 
-	if opt.token != "" {
-		req.Header.Add("Authorization", "Bearer "+opt.token)
+	if opt.lastModified != "" {
+		req.Header.Set("If-Modified-Since", opt.lastModified)
 	}
 
-	resp, err := f.client.Do(req)
+	start := time.Now()
+
+	resp, err := f.doWithRetry(req, opt)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch data: %w", err)
+		return nil, fmt.Errorf("failed to fetch data: %w", err)
 	}
 
 	defer func() {
@@ -85,42 +295,227 @@ func (f *Fetcher) Fetch(ctx context.Context, url, dir string, opts ...FetchOptio
 		}
 	}()
 
+	result := &Result{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+
+	// 304 Not Modified is only expected in response to a conditional request.
+	if resp.StatusCode == http.StatusNotModified && (opt.etag != "" || opt.lastModified != "") {
+		result.NotModified = true
+
+		return result, nil
+	}
+
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return "", fmt.Errorf("failed to fetch url content with status code %d", resp.StatusCode)
+		return nil, statusError(resp)
+	}
+
+	limits := opt.limits.withDefaults()
+	if limits.MaxDownloadSize >= 0 && resp.ContentLength > limits.MaxDownloadSize {
+		return nil, &SizeLimitError{Limit: "max download size", Max: limits.MaxDownloadSize, Unit: "bytes"}
+	}
+
+	if opt.resolveVerify != nil {
+		verifyOpts, err := opt.resolveVerify(ctx)
+		if err != nil {
+			return nil, &VerifyOptionsError{Err: err}
+		}
+
+		for _, fn := range verifyOpts {
+			fn(opt)
+		}
+	}
+
+	verifiers, err := newDigestVerifiers(opt.digests)
+	if err != nil {
+		return nil, err
+	}
+
+	// Content is hashed and verified while it's streamed to the file or the extractor.
+	hash := sha256.New()
+	writers := []io.Writer{hash}
+	for _, v := range verifiers {
+		writers = append(writers, v.digester.Hash())
+	}
+
+	var sigVerifier *signatureVerifier
+	if opt.signature != nil {
+		sigVerifier, err = newSignatureVerifier(opt.signature, opt.verifiers)
+		if err != nil {
+			return nil, err
+		}
+
+		writers = append(writers, sigVerifier)
+	}
+
+	body := &countingReader{r: io.TeeReader(newLimitedReader(resp.Body, limits.MaxDownloadSize), io.MultiWriter(writers...))}
+
+	var extractErr error
+	if opt.filename != "" {
+		err = writeFile(body, dir, opt.filename)
+	} else {
+		_, extractSpan := tracing.Tracer().Start(ctx, "Extract")
+		extractErr = extract(body, dir, opt.format, resp.Header.Get("Content-Type"), ExtractOptions{
+			MaxSize:         limits.MaxExtractedSize,
+			MaxFileCount:    limits.MaxFileCount,
+			StagingDir:      opt.stagingDir,
+			StripComponents: opt.stripComponents,
+			Path:            opt.path,
+		})
+		tracing.End(extractSpan, extractErr)
+
+		// The rest of the content, such as the padding after the end of a tar archive
+		// or everything after a failed entry, must be read to complete the digests.
+		_, err = io.Copy(io.Discard, body)
+	}
+
+	if err != nil {
+		if sigVerifier != nil {
+			sigVerifier.abort(err)
+		}
+
+		return nil, fmt.Errorf("failed to copy file content: %w", err)
+	}
+
+	metrics.DownloadDuration.WithLabelValues(req.URL.Host).Observe(time.Since(start).Seconds())
+	metrics.DownloadBytes.WithLabelValues(req.URL.Host).Observe(float64(body.n))
+
+	result.Digest = hex.EncodeToString(hash.Sum(nil))
+
+	// Content which fails verification is reported as such even if it failed
+	// to extract, and is never published since the error stops the reconciliation.
+	for _, v := range verifiers {
+		if err := v.verify(); err != nil {
+			if sigVerifier != nil {
+				sigVerifier.abort(err)
+			}
+
+			err.Digest = result.Digest
+
+			return nil, err
+		}
 	}
 
-	filename := filepath.Base(url)
+	if sigVerifier != nil {
+		if err := sigVerifier.verify(); err != nil {
+			err.Digest = result.Digest
 
+			return nil, err
+		}
+	}
+
+	if extractErr != nil {
+		return nil, extractErr
+	}
+
+	return result, nil
+}
+
+// writeFile writes the content read from r to the file filename in dir.
+func writeFile(r io.Reader, dir, filename string) error {
 	file, err := os.Create(filepath.Join(dir, filename))
 	if err != nil {
-		return "", fmt.Errorf("failed to open file for writing: %w", err)
+		return fmt.Errorf("failed to open file for writing: %w", err)
 	}
 
-	// split the read to the file and the hash generator
-	tee := io.TeeReader(resp.Body, file)
+	if _, err := io.Copy(file, r); err != nil {
+		_ = file.Close()
 
-	// Create a new SHA256 hash
-	hash := sha256.New()
-	if _, err := io.Copy(hash, tee); err != nil {
-		return "", fmt.Errorf("failed to copy file content: %w", err)
+		return err
 	}
 
-	if err := file.Close(); err != nil {
-		return "", fmt.Errorf("failed to close file: %w", err)
+	return file.Close()
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+
+	return n, err
+}
+
+// withTimeout returns a context which is canceled after the timeout of the options.
+// There's always a deadline, so a stalled server can't block the fetch forever.
+func (opt *FetchOptions) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if opt.timeout <= 0 {
+		opt.timeout = DefaultTimeout
+	}
+
+	return context.WithTimeout(ctx, opt.timeout)
+}
+
+// timeoutError points out the exceeded timeout as the cause of err.
+func (opt *FetchOptions) timeoutError(ctx context.Context, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
 	}
 
-	// re-open for the untar operation
-	file, err = os.Open(filepath.Join(dir, filename))
+	return fmt.Errorf("timeout of %s exceeded: %w", opt.timeout, err)
+}
+
+// newRequest constructs the GET request for url with the configured credentials.
+func (f *Fetcher) newRequest(ctx context.Context, url string, opt *FetchOptions) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to open file for writing: %w", err)
+		return nil, fmt.Errorf("failed to generate request for url '%s': %w", url, err)
+	}
+
+	for name, values := range opt.headers {
+		req.Header[name] = values
+	}
+
+	if opt.username != "" && opt.password != "" {
+		req.SetBasicAuth(opt.username, opt.password)
+	}
+
+	if opt.token != "" {
+		req.Header.Set("Authorization", "Bearer "+opt.token)
 	}
 
-	defer file.Close()
+	return req, nil
+}
+
+// do sends the request and counts the response status code per host.
+// The request is traced and carries the W3C trace context to the server.
+func (f *Fetcher) do(req *http.Request, opt *FetchOptions) (_ *http.Response, retErr error) {
+	ctx, span := tracing.Tracer().Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(redactURL(req.URL)),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+	)
+	defer func() {
+		tracing.End(span, retErr)
+	}()
+
+	req = req.WithContext(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
-	// Untar the archive.
-	if err := tar.Untar(file, dir); err != nil {
-		return "", fmt.Errorf("failed to untar file content: %w", err)
+	resp, err := f.clientFor(opt).Do(req)
+	if err != nil {
+		return nil, err
 	}
 
-	return hex.EncodeToString(hash.Sum(nil)), nil
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	metrics.Responses.WithLabelValues(req.URL.Host, strconv.Itoa(resp.StatusCode)).Inc()
+
+	return resp, nil
+}
+
+// redactURL returns the URL without user info and query, which may contain credentials.
+func redactURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	redacted.RawQuery = ""
+
+	return redacted.String()
 }
@@ -0,0 +1,259 @@
+package fetcher
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestFetchTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	})
+
+	var traceparent string
+	testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Write([]byte("content"))
+	}))
+	defer testserver.Close()
+
+	_, err := NewFetcher(testserver.Client()).Fetch(context.Background(), testserver.URL+"/content.txt?token=secret", t.TempDir(), WithFile("content.txt"))
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "HTTP GET", spans[0].Name())
+	assert.Equal(t, "Fetch", spans[1].Name())
+	assert.Equal(t, spans[1].SpanContext().TraceID(), spans[0].SpanContext().TraceID())
+	assert.Contains(t, traceparent, spans[0].SpanContext().TraceID().String())
+
+	for _, attr := range spans[0].Attributes() {
+		assert.NotContains(t, attr.Value.Emit(), "secret")
+	}
+}
+
+func TestFetchMaxDownloadSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		chunked bool
+	}{
+		{
+			name: "with content length",
+		},
+		{
+			name:    "while streaming",
+			chunked: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("content"))
+				if tt.chunked {
+					w.(http.Flusher).Flush()
+				}
+				w.Write([]byte(" exceeding the limit"))
+			}))
+			defer testserver.Close()
+
+			_, err := NewFetcher(testserver.Client()).Fetch(context.Background(), testserver.URL+"/content.txt", t.TempDir(),
+				WithFile("content.txt"), WithLimits(Limits{MaxDownloadSize: 10}))
+
+			var sizeErr *SizeLimitError
+			require.ErrorAs(t, err, &sizeErr)
+			assert.ErrorContains(t, err, "content exceeds the max download size of 10 bytes")
+		})
+	}
+}
+
+func TestLimitsNarrow(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   Limits
+		other    Limits
+		expected Limits
+	}{
+		{
+			name:     "should keep the limits without other limits",
+			limits:   Limits{MaxDownloadSize: 10, MaxExtractedSize: -1},
+			expected: Limits{MaxDownloadSize: 10, MaxExtractedSize: -1, MaxFileCount: DefaultMaxFileCount},
+		},
+		{
+			name:     "should lower the limits",
+			limits:   Limits{MaxDownloadSize: 10, MaxExtractedSize: -1},
+			other:    Limits{MaxDownloadSize: 5, MaxExtractedSize: 20, MaxFileCount: 30},
+			expected: Limits{MaxDownloadSize: 5, MaxExtractedSize: 20, MaxFileCount: 30},
+		},
+		{
+			name:     "should not raise or disable the limits",
+			limits:   Limits{MaxDownloadSize: 10, MaxExtractedSize: 10},
+			other:    Limits{MaxDownloadSize: 20, MaxExtractedSize: -1, MaxFileCount: DefaultMaxFileCount + 1},
+			expected: Limits{MaxDownloadSize: 10, MaxExtractedSize: 10, MaxFileCount: DefaultMaxFileCount},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.limits.Narrow(tt.other))
+		})
+	}
+}
+
+func TestFetchArchive(t *testing.T) {
+	content := compress(t, tarball(t, map[string]string{"app/manifest.yaml": "kind: ConfigMap"}), func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriter(w), nil
+	})
+	sum := sha256.Sum256(content)
+
+	tests := []struct {
+		name      string
+		content   []byte
+		opts      []FetchOptionsFn
+		assertErr func(t *testing.T, err error)
+	}{
+		{
+			name:    "extracts without keeping the archive",
+			content: content,
+			opts:    []FetchOptionsFn{WithDigest("sha256:" + hex.EncodeToString(sum[:]))},
+			assertErr: func(t *testing.T, err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name:    "reports a digest mismatch before an extraction failure",
+			content: []byte("not an archive"),
+			opts:    []FetchOptionsFn{WithDigest("sha256:" + hex.EncodeToString(sum[:])), WithFormat(FormatTarGzip)},
+			assertErr: func(t *testing.T, err error) {
+				var mismatchErr *DigestMismatchError
+				require.ErrorAs(t, err, &mismatchErr)
+				rejected := sha256.Sum256([]byte("not an archive"))
+				assert.Equal(t, hex.EncodeToString(rejected[:]), mismatchErr.Digest)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(tt.content)
+			}))
+			defer testserver.Close()
+
+			dir := t.TempDir()
+			result, err := NewFetcher(testserver.Client()).Fetch(context.Background(), testserver.URL+"/content.tar.gz", dir, tt.opts...)
+			tt.assertErr(t, err)
+			if err != nil {
+				return
+			}
+
+			assert.Equal(t, hex.EncodeToString(sum[:]), result.Digest)
+
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			assert.Equal(t, "app", entries[0].Name())
+		})
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content"))
+		w.(http.Flusher).Flush()
+		// Stall the transfer of the rest of the content.
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer testserver.Close()
+
+	_, err := NewFetcher(testserver.Client()).Fetch(context.Background(), testserver.URL+"/content.txt", t.TempDir(),
+		WithFile("content.txt"), WithTimeout(50*time.Millisecond))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "timeout of 50ms exceeded")
+}
+
+func TestFetchHeaders(t *testing.T) {
+	var header http.Header
+	testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.Write([]byte("content"))
+	}))
+	defer testserver.Close()
+
+	_, err := NewFetcher(testserver.Client()).Fetch(context.Background(), testserver.URL+"/content.txt", t.TempDir(),
+		WithFile("content.txt"),
+		WithHeaders(map[string]string{"accept": "application/octet-stream", "Authorization": "Basic ignored"}),
+		WithHeaders(map[string]string{"X-API-Key": "key"}),
+		WithToken("token"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "application/octet-stream", header.Get("Accept"))
+	assert.Equal(t, "key", header.Get("X-API-Key"))
+	assert.Equal(t, "Bearer token", header.Get("Authorization"))
+}
+
+func TestFetchSecretHeadersRedirect(t *testing.T) {
+	var header http.Header
+	testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if target := r.URL.Query().Get("to"); target != "" {
+			http.Redirect(w, r, target, http.StatusFound)
+			return
+		}
+		header = r.Header
+		w.Write([]byte("content"))
+	}))
+	defer testserver.Close()
+
+	origin, err := url.Parse(testserver.URL)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		target   string
+		expected string
+	}{
+		{
+			name:     "should keep secret headers on redirects to the same host",
+			target:   "http://" + origin.Host + "/content.txt",
+			expected: "secret",
+		},
+		{
+			name:   "should drop secret headers on redirects to another host",
+			target: "http://localhost:" + origin.Port() + "/content.txt",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header = nil
+			_, err := NewFetcher(testserver.Client()).Fetch(context.Background(), testserver.URL+"/?to="+url.QueryEscape(tt.target), t.TempDir(),
+				WithFile("content.txt"),
+				WithHeaders(map[string]string{"Accept": "text/plain"}),
+				WithSecretHeaders(map[string]string{"Private-Token": "secret"}))
+			require.NoError(t, err)
+
+			require.NotNil(t, header)
+			assert.Equal(t, "text/plain", header.Get("Accept"))
+			assert.Equal(t, tt.expected, header.Get("Private-Token"))
+		})
+	}
+}
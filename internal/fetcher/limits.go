@@ -0,0 +1,116 @@
+package fetcher
+
+import (
+	"io"
+)
+
+const (
+	// DefaultMaxDownloadSize is the default limit for the size of fetched content.
+	DefaultMaxDownloadSize int64 = 100 << (10 * 2)
+
+	// DefaultMaxExtractedSize is the default limit for the total size of extracted files.
+	DefaultMaxExtractedSize int64 = 100 << (10 * 2)
+
+	// DefaultMaxFileCount is the default limit for the number of extracted files and directories.
+	DefaultMaxFileCount int64 = 10000
+)
+
+// Limits bounds the fetched and extracted content. They're enforced while
+// streaming, so content exceeding them is never fully written.
+// Zero values fall back to the defaults, negative values disable the limit.
+type Limits struct {
+	// MaxDownloadSize is the maximum size of the fetched content in bytes.
+	MaxDownloadSize int64
+
+	// MaxExtractedSize is the maximum total size of the extracted files in bytes.
+	MaxExtractedSize int64
+
+	// MaxFileCount is the maximum number of extracted files and directories.
+	MaxFileCount int64
+}
+
+// WithLimits bounds the fetched and extracted content of the URL fetch.
+func WithLimits(limits Limits) FetchOptionsFn {
+	return func(opt *FetchOptions) {
+		opt.limits = limits
+	}
+}
+
+// withDefaults returns the limits with zero values replaced by the defaults.
+func (l Limits) withDefaults() Limits {
+	if l.MaxDownloadSize == 0 {
+		l.MaxDownloadSize = DefaultMaxDownloadSize
+	}
+
+	if l.MaxExtractedSize == 0 {
+		l.MaxExtractedSize = DefaultMaxExtractedSize
+	}
+
+	if l.MaxFileCount == 0 {
+		l.MaxFileCount = DefaultMaxFileCount
+	}
+
+	return l
+}
+
+// Narrow returns the limits lowered to the positive values of other. Limits
+// can't be raised or disabled this way, zero and negative values of other
+// keep the limit.
+func (l Limits) Narrow(other Limits) Limits {
+	l = l.withDefaults()
+	l.MaxDownloadSize = narrow(l.MaxDownloadSize, other.MaxDownloadSize)
+	l.MaxExtractedSize = narrow(l.MaxExtractedSize, other.MaxExtractedSize)
+	l.MaxFileCount = narrow(l.MaxFileCount, other.MaxFileCount)
+
+	return l
+}
+
+func narrow(limit, other int64) int64 {
+	if other <= 0 {
+		return limit
+	}
+
+	if limit < 0 || other < limit {
+		return other
+	}
+
+	return limit
+}
+
+// limitedReader fails with a SizeLimitError once more than max bytes are read.
+type limitedReader struct {
+	r    io.Reader
+	max  int64
+	read int64
+}
+
+func newLimitedReader(r io.Reader, max int64) io.Reader {
+	if max < 0 {
+		return r
+	}
+
+	return &limitedReader{r: r, max: max}
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.read > l.max {
+		return 0, l.err()
+	}
+
+	// Read one byte more than allowed to detect exceeding the limit.
+	if remaining := l.max - l.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.max {
+		return n, l.err()
+	}
+
+	return n, err
+}
+
+func (l *limitedReader) err() error {
+	return &SizeLimitError{Limit: "max download size", Max: l.max, Unit: "bytes"}
+}
@@ -0,0 +1,111 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Location is a URL the content can be fetched from, together with the options
+// which only apply to it, such as credentials.
+type Location struct {
+	URL     string
+	Options []FetchOptionsFn
+}
+
+// LocationsError is returned when the content couldn't be fetched from any of
+// the locations. Errs holds the error of every location in order.
+type LocationsError struct {
+	Errs []error
+}
+
+func (e *LocationsError) Error() string {
+	msgs := make([]string, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	return fmt.Sprintf("failed to fetch from all %d locations: %s", len(e.Errs), strings.Join(msgs, "; "))
+}
+
+func (e *LocationsError) Unwrap() []error {
+	return e.Errs
+}
+
+// FetchFirst fetches the content from the locations in order until one of
+// them serves it. The options apply to all locations. Content which fails
+// verification, exceeds a limit or can't be extracted ends the failover, since
+// all locations are expected to serve the same content. So does a failure to
+// resolve the verify options, which don't depend on the location.
+func (f *Fetcher) FetchFirst(ctx context.Context, locations []Location, dir string, opts ...FetchOptionsFn) (*Result, error) {
+	if len(locations) == 0 {
+		return nil, errors.New("no locations to fetch from")
+	}
+
+	errs := make([]error, 0, len(locations))
+	for i, location := range locations {
+		// Remove what a failed location left behind.
+		if i > 0 {
+			if err := clearDir(dir); err != nil {
+				return nil, err
+			}
+		}
+
+		result, err := f.Fetch(ctx, location.URL, dir, append(slices.Clone(opts), location.Options...)...)
+		if err == nil {
+			result.URL = location.URL
+
+			return result, nil
+		}
+
+		var verifyErr *VerifyOptionsError
+		if isContentError(err) || errors.As(err, &verifyErr) {
+			return nil, err
+		}
+
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if len(errs) == 1 {
+		return nil, errs[0]
+	}
+
+	return nil, &LocationsError{Errs: errs}
+}
+
+// isContentError returns true if err is caused by the fetched content rather
+// than by fetching it.
+func isContentError(err error) bool {
+	var (
+		mismatchErr  *DigestMismatchError
+		signatureErr *SignatureVerificationError
+		sizeErr      *SizeLimitError
+		archiveErr   *ArchiveError
+	)
+
+	return errors.As(err, &mismatchErr) || errors.As(err, &signatureErr) ||
+		errors.As(err, &sizeErr) || errors.As(err, &archiveErr)
+}
+
+// clearDir removes all entries of dir.
+func clearDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return fmt.Errorf("failed to clear directory: %w", err)
+		}
+	}
+
+	return nil
+}
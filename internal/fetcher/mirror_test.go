@@ -0,0 +1,110 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchFirst(t *testing.T) {
+	handlers := map[string]http.HandlerFunc{
+		"unavailable": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+		"unauthorized": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		},
+		"content": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("content"))
+		},
+		"tampered": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("tampered"))
+		},
+	}
+
+	const contentDigest = "sha256:ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73"
+
+	tests := []struct {
+		name      string
+		locations []string
+		opts      []FetchOptionsFn
+		expected  string
+		assertErr func(t *testing.T, err error)
+	}{
+		{
+			name:      "fails over to the next location",
+			locations: []string{"unavailable", "unauthorized", "content"},
+			expected:  "content",
+		},
+		{
+			name:      "stops at content not matching the digest",
+			locations: []string{"tampered", "content"},
+			opts:      []FetchOptionsFn{WithDigest(contentDigest)},
+			assertErr: func(t *testing.T, err error) {
+				var mismatchErr *DigestMismatchError
+				require.ErrorAs(t, err, &mismatchErr)
+			},
+		},
+		{
+			name:      "stops at a failure to resolve the verify options",
+			locations: []string{"content", "content"},
+			opts: []FetchOptionsFn{WithVerifyOptions(func(ctx context.Context) ([]FetchOptionsFn, error) {
+				return nil, errors.New("checksum file not found")
+			})},
+			assertErr: func(t *testing.T, err error) {
+				var verifyErr *VerifyOptionsError
+				require.ErrorAs(t, err, &verifyErr)
+				var locationsErr *LocationsError
+				assert.False(t, errors.As(err, &locationsErr))
+				assert.EqualError(t, err, "checksum file not found")
+			},
+		},
+		{
+			name:      "reports the errors of all locations",
+			locations: []string{"unavailable", "unauthorized"},
+			assertErr: func(t *testing.T, err error) {
+				var locationsErr *LocationsError
+				require.ErrorAs(t, err, &locationsErr)
+				require.Len(t, locationsErr.Errs, 2)
+
+				var authErr *AuthError
+				assert.ErrorAs(t, err, &authErr)
+				assert.ErrorContains(t, err, "failed to fetch from all 2 locations")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlers[filepath.Base(filepath.Dir(r.URL.Path))](w, r)
+			}))
+			defer testserver.Close()
+
+			locations := make([]Location, 0, len(tt.locations))
+			for _, name := range tt.locations {
+				locations = append(locations, Location{URL: testserver.URL + "/" + name + "/content.txt"})
+			}
+
+			dir := t.TempDir()
+			opts := append([]FetchOptionsFn{WithFile("content.txt"), WithRetryPolicy(RetryPolicy{MaxAttempts: 1})}, tt.opts...)
+			result, err := NewFetcher(testserver.Client()).FetchFirst(context.Background(), locations, dir, opts...)
+			if tt.assertErr != nil {
+				tt.assertErr(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, testserver.URL+"/"+tt.expected+"/content.txt", result.URL)
+			content, err := os.ReadFile(filepath.Join(dir, "content.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, "content", string(content))
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// maxDrainSize limits how much of the body of a retried response is read to
+// reuse the connection. Larger bodies are dropped along with the connection.
+const maxDrainSize = 64 << 10
+
+// RetryPolicy configures how requests are retried on transient failures, which
+// are network errors and the status codes 408, 429, 500, 502, 503 and 504.
+// Other responses, e.g. 401 or 404, fail fast.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+
+	// Backoff is the wait before the first retry, which doubles with every further retry.
+	Backoff time.Duration
+
+	// MaxBackoff caps the wait between attempts, zero doesn't cap it. Retry-After
+	// headers asking for a longer wait end the retries, the wait is reported by
+	// HTTPStatusError. So do Retry-After headers asking to wait past the deadline
+	// of the request.
+	MaxBackoff time.Duration
+}
+
+// WithRetryPolicy retries the requests of the URL fetch according to policy.
+func WithRetryPolicy(policy RetryPolicy) FetchOptionsFn {
+	return func(opt *FetchOptions) {
+		opt.retryPolicy = policy
+	}
+}
+
+var retryableStatusCodes = map[int]bool{
+	http.StatusRequestTimeout:      true,
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// doWithRetry sends the request until it succeeds, fails permanently or the
+// attempts are exhausted. The response of the last attempt is returned.
+func (f *Fetcher) doWithRetry(req *http.Request, opt *FetchOptions) (*http.Response, error) {
+	policy := opt.retryPolicy
+	ctx := req.Context()
+
+	for attempt := 1; ; attempt++ {
+		resp, err := f.do(req, opt)
+
+		retryable := err != nil && isTransient(err) || err == nil && retryableStatusCodes[resp.StatusCode]
+		if !retryable || attempt >= policy.MaxAttempts || ctx.Err() != nil {
+			if err != nil && attempt > 1 {
+				err = fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+
+			return resp, err
+		}
+
+		wait := policy.backoff(attempt)
+		if err == nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				if policy.MaxBackoff > 0 && retryAfter > policy.MaxBackoff || exceedsDeadline(ctx, retryAfter) {
+					// Waiting that long would block the reconciliation, leave it to the requeue.
+					return resp, nil
+				}
+
+				wait = retryAfter
+			}
+
+			// Drain the body so the connection can be reused.
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainSize))
+			_ = resp.Body.Close()
+
+			log.FromContext(ctx).V(1).Info("retrying request", "url", redactURL(req.URL), "statusCode", resp.StatusCode, "attempt", attempt, "wait", wait)
+		} else {
+			log.FromContext(ctx).V(1).Info("retrying request", "url", redactURL(req.URL), "error", err.Error(), "attempt", attempt, "wait", wait)
+		}
+
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// isTransient reports whether the request error may go away on retry.
+// Certificate errors won't.
+func isTransient(err error) bool {
+	var (
+		verificationErr *tls.CertificateVerificationError
+		authorityErr    x509.UnknownAuthorityError
+		hostnameErr     x509.HostnameError
+		certificateErr  x509.CertificateInvalidError
+	)
+
+	return !errors.As(err, &verificationErr) &&
+		!errors.As(err, &authorityErr) &&
+		!errors.As(err, &hostnameErr) &&
+		!errors.As(err, &certificateErr)
+}
+
+// exceedsDeadline reports whether waiting for d would end after the deadline of ctx.
+func exceedsDeadline(ctx context.Context, d time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+
+	return ok && time.Until(deadline) < d
+}
+
+// backoff returns the exponential wait after the given attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	limit := p.MaxBackoff
+	if limit <= 0 {
+		// Doubling any further would overflow.
+		limit = math.MaxInt64 / 2
+	}
+
+	wait := p.Backoff
+	for i := 1; i < attempt && wait < limit; i++ {
+		wait *= 2
+	}
+
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+
+	return wait
+}
+
+// parseRetryAfter parses the Retry-After header given either in seconds or as HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return max(time.Until(date), 0), true
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return errors.Join(errors.New("retry aborted"), ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,153 @@
+package fetcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchRetry(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+	}
+
+	tests := []struct {
+		name         string
+		responses    []int
+		retryAfter   string
+		policy       RetryPolicy
+		timeout      time.Duration
+		wantAttempts int
+		assertErr    func(t *testing.T, err error)
+	}{
+		{
+			name:         "retries transient failures",
+			responses:    []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			policy:       policy,
+			wantAttempts: 3,
+		},
+		{
+			name:         "gives up after max attempts",
+			responses:    []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusOK},
+			policy:       policy,
+			wantAttempts: 3,
+			assertErr: func(t *testing.T, err error) {
+				require.ErrorContains(t, err, "status code 429")
+			},
+		},
+		{
+			name:         "fails fast on not found",
+			responses:    []int{http.StatusNotFound, http.StatusOK},
+			policy:       policy,
+			wantAttempts: 1,
+			assertErr: func(t *testing.T, err error) {
+				require.ErrorContains(t, err, "status code 404")
+			},
+		},
+		{
+			name:         "fails fast on unauthorized",
+			responses:    []int{http.StatusUnauthorized, http.StatusOK},
+			policy:       policy,
+			wantAttempts: 1,
+			assertErr: func(t *testing.T, err error) {
+				require.ErrorContains(t, err, "status code 401")
+			},
+		},
+		{
+			name:         "honors retry after",
+			responses:    []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:   "0",
+			policy:       policy,
+			wantAttempts: 2,
+		},
+		{
+			name:         "stops on retry after exceeding the max backoff",
+			responses:    []int{http.StatusServiceUnavailable, http.StatusOK},
+			retryAfter:   "120",
+			policy:       policy,
+			wantAttempts: 1,
+			assertErr: func(t *testing.T, err error) {
+				var statusErr *HTTPStatusError
+				require.ErrorAs(t, err, &statusErr)
+				assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+				assert.Equal(t, 2*time.Minute, statusErr.RetryAfter)
+			},
+		},
+		{
+			name:         "honors retry after without max backoff",
+			responses:    []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:   "0",
+			policy:       RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+			wantAttempts: 2,
+		},
+		{
+			name:         "stops on retry after exceeding the timeout",
+			responses:    []int{http.StatusServiceUnavailable, http.StatusOK},
+			retryAfter:   "120",
+			policy:       RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+			timeout:      time.Minute,
+			wantAttempts: 1,
+			assertErr: func(t *testing.T, err error) {
+				var statusErr *HTTPStatusError
+				require.ErrorAs(t, err, &statusErr)
+				assert.Equal(t, 2*time.Minute, statusErr.RetryAfter)
+			},
+		},
+		{
+			name:         "does not retry without policy",
+			responses:    []int{http.StatusServiceUnavailable, http.StatusOK},
+			wantAttempts: 1,
+			assertErr: func(t *testing.T, err error) {
+				require.ErrorContains(t, err, "status code 503")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				code := tt.responses[attempts]
+				attempts++
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(code)
+				w.Write([]byte("content"))
+			}))
+			defer testserver.Close()
+
+			_, err := NewFetcher(testserver.Client()).Fetch(context.Background(), testserver.URL+"/content.txt", t.TempDir(),
+				WithFile("content.txt"), WithRetryPolicy(tt.policy), WithTimeout(tt.timeout))
+			if tt.assertErr != nil {
+				tt.assertErr(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantAttempts, attempts)
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	assert.Equal(t, time.Second, policy.backoff(1))
+	assert.Equal(t, 2*time.Second, policy.backoff(2))
+	assert.Equal(t, 4*time.Second, policy.backoff(3))
+	assert.Equal(t, 5*time.Second, policy.backoff(4))
+
+	// Without a max backoff, the wait keeps doubling.
+	policy.MaxBackoff = 0
+	assert.Equal(t, time.Second, policy.backoff(1))
+	assert.Equal(t, 2*time.Second, policy.backoff(2))
+	assert.Equal(t, 4*time.Second, policy.backoff(3))
+	assert.Equal(t, 8*time.Second, policy.backoff(4))
+	assert.Positive(t, policy.backoff(100))
+}
@@ -0,0 +1,161 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+// WithSignature verifies the URL content against the detached signature before
+// it's extracted. The content is accepted if any of the verifiers accepts the signature.
+func WithSignature(sig []byte, verifiers ...signature.Verifier) FetchOptionsFn {
+	return func(opt *FetchOptions) {
+		opt.signature = sig
+		opt.verifiers = verifiers
+	}
+}
+
+// SignatureVerificationError is returned when the fetched content doesn't match the signature.
+type SignatureVerificationError struct {
+	Err error
+	// Digest is the hex encoded SHA256 digest of the rejected content, like
+	// Result.Digest. It's empty if the content wasn't fetched.
+	Digest string
+}
+
+func (e *SignatureVerificationError) Error() string {
+	return fmt.Sprintf("signature verification failed: %s", e.Err)
+}
+
+func (e *SignatureVerificationError) Unwrap() error {
+	return e.Err
+}
+
+// signatureVerifier verifies the signature of the content written to it with
+// all verifiers concurrently, so the content doesn't need to be read twice.
+type signatureVerifier struct {
+	pipes  []*io.PipeWriter
+	writer io.Writer
+	errs   chan error
+}
+
+func newSignatureVerifier(sig []byte, verifiers []signature.Verifier) (*signatureVerifier, error) {
+	if len(verifiers) == 0 {
+		return nil, &SignatureVerificationError{Err: errors.New("no public keys given")}
+	}
+
+	v := &signatureVerifier{errs: make(chan error, len(verifiers))}
+	writers := make([]io.Writer, 0, len(verifiers))
+	for _, verifier := range verifiers {
+		pr, pw := io.Pipe()
+		v.pipes = append(v.pipes, pw)
+		writers = append(writers, pw)
+
+		go func() {
+			err := verifier.VerifySignature(bytes.NewReader(sig), pr)
+			// Drain the remaining content so a verifier returning early doesn't block the writer.
+			_, _ = io.Copy(io.Discard, pr)
+			v.errs <- err
+		}()
+	}
+
+	v.writer = io.MultiWriter(writers...)
+
+	return v, nil
+}
+
+func (v *signatureVerifier) Write(p []byte) (int, error) {
+	return v.writer.Write(p)
+}
+
+// verify ends the content and accepts the signature if any of the verifiers accepts it.
+func (v *signatureVerifier) verify() *SignatureVerificationError {
+	for _, pw := range v.pipes {
+		_ = pw.Close()
+	}
+
+	var errs []error
+	for range v.pipes {
+		if err := <-v.errs; err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) < len(v.pipes) {
+		return nil
+	}
+
+	return &SignatureVerificationError{Err: errors.Join(errs...)}
+}
+
+// abort stops the verifiers if the content couldn't be read completely.
+func (v *signatureVerifier) abort(err error) {
+	for _, pw := range v.pipes {
+		_ = pw.CloseWithError(err)
+	}
+
+	for range v.pipes {
+		<-v.errs
+	}
+}
+
+// FetchSignature downloads the base64 encoded detached signature at url, as
+// produced by 'cosign sign-blob'.
+func (f *Fetcher) FetchSignature(ctx context.Context, url string, opts ...FetchOptionsFn) ([]byte, error) {
+	content, err := f.fetchSmall(ctx, url, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signature: %w", err)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(content)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	return sig, nil
+}
+
+// signatureBundle holds the fields of the cosign and Sigstore bundle formats
+// carrying the signature of a blob.
+type signatureBundle struct {
+	// Base64Signature is set by 'cosign sign-blob --bundle'.
+	Base64Signature string `json:"base64Signature"`
+	// MessageSignature is set by Sigstore bundles.
+	MessageSignature *struct {
+		Signature []byte `json:"signature"`
+	} `json:"messageSignature"`
+}
+
+// FetchSignatureBundle downloads the cosign or Sigstore bundle at url and returns
+// the signature it contains. Transparency log entries are not verified.
+func (f *Fetcher) FetchSignatureBundle(ctx context.Context, url string, opts ...FetchOptionsFn) ([]byte, error) {
+	content, err := f.fetchSmall(ctx, url, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signature bundle: %w", err)
+	}
+
+	bundle := &signatureBundle{}
+	if err := json.Unmarshal(content, bundle); err != nil {
+		return nil, fmt.Errorf("failed to parse signature bundle: %w", err)
+	}
+
+	switch {
+	case bundle.Base64Signature != "":
+		sig, err := base64.StdEncoding.DecodeString(bundle.Base64Signature)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode signature: %w", err)
+		}
+
+		return sig, nil
+	case bundle.MessageSignature != nil && len(bundle.MessageSignature.Signature) > 0:
+		return bundle.MessageSignature.Signature, nil
+	default:
+		return nil, errors.New("signature bundle contains no signature")
+	}
+}
@@ -0,0 +1,127 @@
+package fetcher
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// cachedTransport is a dedicated transport together with the version of the
+// configuration it was built from.
+type cachedTransport struct {
+	version   string
+	transport *http.Transport
+}
+
+// clientFor returns the shared client unless the options require a dedicated
+// transport for a TLS or proxy configuration. Dedicated transports are cached by the transport key of the options,
+// which is forgotten once the options don't require a dedicated transport anymore.
+// Secret headers are dropped on redirects to other hosts by a copy of the client.
+func (f *Fetcher) clientFor(opt *FetchOptions) *http.Client {
+	client := f.client
+	if opt.tlsConfig != nil || opt.proxy != nil {
+		client = &http.Client{
+			Transport:     f.transportFor(opt),
+			CheckRedirect: f.client.CheckRedirect,
+			Jar:           f.client.Jar,
+			Timeout:       f.client.Timeout,
+		}
+	} else if opt.transportKey != "" {
+		f.RemoveTransport(opt.transportKey)
+	}
+
+	if len(opt.secretHeaders) == 0 {
+		return client
+	}
+
+	return &http.Client{
+		Transport:     client.Transport,
+		CheckRedirect: opt.checkRedirect(client.CheckRedirect),
+		Jar:           client.Jar,
+		Timeout:       client.Timeout,
+	}
+}
+
+// checkRedirect removes the secret headers from redirects that don't go to the
+// scheme and host of the original request, then applies next. The client copies
+// the headers of the original request to every redirect, so they're restored
+// when a redirect leads back to the original host.
+func (opt *FetchOptions) checkRedirect(next func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if origin := via[0].URL; req.URL.Scheme != origin.Scheme || req.URL.Host != origin.Host {
+			for _, name := range opt.secretHeaders {
+				req.Header.Del(name)
+			}
+		}
+
+		if next != nil {
+			return next(req, via)
+		}
+
+		// Keep the default policy of the client.
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+
+		return nil
+	}
+}
+
+func (f *Fetcher) transportFor(opt *FetchOptions) *http.Transport {
+	if opt.transportKey == "" {
+		return f.newTransport(opt)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cached, ok := f.transports[opt.transportKey]
+	if ok && cached.version == opt.transportVersion {
+		return cached.transport
+	}
+
+	if ok {
+		cached.transport.CloseIdleConnections()
+	}
+
+	transport := f.newTransport(opt)
+	f.transports[opt.transportKey] = cachedTransport{
+		version:   opt.transportVersion,
+		transport: transport,
+	}
+
+	return transport
+}
+
+// RemoveTransport closes and forgets the dedicated transport cached under key.
+func (f *Fetcher) RemoveTransport(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if cached, ok := f.transports[key]; ok {
+		cached.transport.CloseIdleConnections()
+		delete(f.transports, key)
+	}
+}
+
+// newTransport clones the transport of the shared client and applies the options to it.
+func (f *Fetcher) newTransport(opt *FetchOptions) *http.Transport {
+	base, ok := f.client.Transport.(*http.Transport)
+	if !ok || base == nil {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+
+	transport := base.Clone()
+	if opt.tlsConfig != nil {
+		transport.TLSClientConfig = opt.tlsConfig.Clone()
+	}
+
+	if opt.proxy != nil {
+		proxyFunc := opt.proxy.ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	return transport
+}
@@ -0,0 +1,88 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchProxy(t *testing.T) {
+	tests := []struct {
+		name      string
+		noProxy   string
+		assertErr func(t *testing.T, err error)
+		proxied   int
+	}{
+		{
+			name: "through the proxy",
+			assertErr: func(t *testing.T, err error) {
+				require.NoError(t, err)
+			},
+			proxied: 1,
+		},
+		{
+			name:    "bypassing the proxy",
+			noProxy: "internal.example.com,content.invalid",
+			assertErr: func(t *testing.T, err error) {
+				require.Error(t, err)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxied := 0
+			proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				expected := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass"))
+				if r.URL.Host != "content.invalid" || r.Header.Get("Proxy-Authorization") != expected {
+					w.WriteHeader(http.StatusProxyAuthRequired)
+					return
+				}
+				proxied++
+				w.Write([]byte("content"))
+			}))
+			defer proxy.Close()
+
+			proxyURL, err := url.Parse(proxy.URL)
+			require.NoError(t, err)
+			proxyURL.User = url.UserPassword("user", "pass")
+
+			_, err = NewFetcher(proxy.Client()).Fetch(context.Background(), "http://content.invalid/content.txt", t.TempDir(),
+				WithFile("content.txt"), WithProxy(proxyURL, tt.noProxy), WithTimeout(5*time.Second))
+			tt.assertErr(t, err)
+			assert.Equal(t, tt.proxied, proxied)
+		})
+	}
+}
+
+func TestTransportCache(t *testing.T) {
+	testserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content"))
+	}))
+	defer testserver.Close()
+
+	f := NewFetcher(testserver.Client())
+	fetch := func(opts ...FetchOptionsFn) {
+		_, err := f.Fetch(context.Background(), testserver.URL+"/content.txt", t.TempDir(),
+			append([]FetchOptionsFn{WithFile("content.txt")}, opts...)...)
+		require.NoError(t, err)
+	}
+
+	fetch(WithTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12}), WithTransportCacheKey("default/test", "v1"))
+	require.Contains(t, f.transports, "default/test")
+	cached := f.transports["default/test"].transport
+
+	fetch(WithTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12}), WithTransportCacheKey("default/test", "v1"))
+	assert.Same(t, cached, f.transports["default/test"].transport)
+
+	// The transport is forgotten once the configuration is removed.
+	fetch(WithTransportCacheKey("default/test", ""))
+	assert.NotContains(t, f.transports, "default/test")
+}
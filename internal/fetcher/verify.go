@@ -0,0 +1,182 @@
+package fetcher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	// Register sha384 and sha512 for the digest algorithms.
+	_ "crypto/sha512"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// maxVerificationFileSize limits the size of downloaded checksum and signature files.
+const maxVerificationFileSize = 1 << 20
+
+// checksumAlgorithms maps the length of hex encoded checksums to their algorithm.
+var checksumAlgorithms = map[int]digest.Algorithm{
+	64:  digest.SHA256,
+	96:  digest.SHA384,
+	128: digest.SHA512,
+}
+
+// WithDigest verifies the URL content against the expected digest in the form
+// '<algorithm>:<hex>' before it's extracted. It can be given multiple times.
+func WithDigest(expected string) FetchOptionsFn {
+	return func(opt *FetchOptions) {
+		opt.digests = append(opt.digests, expected)
+	}
+}
+
+// VerifyOptionsFunc resolves the options verifying the fetched content, such as
+// WithDigest and WithSignature.
+type VerifyOptionsFunc func(ctx context.Context) ([]FetchOptionsFn, error)
+
+// WithVerifyOptions resolves the options verifying the URL content once the
+// server responds with content, so that e.g. checksum files aren't fetched
+// for content which isn't modified. An error of resolve ends the fetch with a
+// VerifyOptionsError.
+func WithVerifyOptions(resolve VerifyOptionsFunc) FetchOptionsFn {
+	return func(opt *FetchOptions) {
+		opt.resolveVerify = resolve
+	}
+}
+
+// VerifyOptionsError is returned when the options verifying the content can't
+// be resolved. It's the error of the VerifyOptionsFunc as it is.
+type VerifyOptionsError struct {
+	Err error
+}
+
+func (e *VerifyOptionsError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *VerifyOptionsError) Unwrap() error {
+	return e.Err
+}
+
+// DigestMismatchError is returned when the fetched content doesn't match an expected digest.
+type DigestMismatchError struct {
+	Expected string
+	Actual   string
+	// Digest is the hex encoded SHA256 digest of the rejected content, like Result.Digest.
+	Digest string
+}
+
+func (e *DigestMismatchError) Error() string {
+	return fmt.Sprintf("digest mismatch: expected '%s' but got '%s'", e.Expected, e.Actual)
+}
+
+// digestVerifier computes the digest of the content written to it and compares
+// it to the expected digest.
+type digestVerifier struct {
+	expected digest.Digest
+	digester digest.Digester
+}
+
+func newDigestVerifiers(expected []string) ([]digestVerifier, error) {
+	verifiers := make([]digestVerifier, 0, len(expected))
+	for _, e := range expected {
+		d, err := digest.Parse(e)
+		if err != nil {
+			return nil, fmt.Errorf("invalid digest '%s': %w", e, err)
+		}
+
+		verifiers = append(verifiers, digestVerifier{
+			expected: d,
+			digester: d.Algorithm().Digester(),
+		})
+	}
+
+	return verifiers, nil
+}
+
+func (v digestVerifier) verify() *DigestMismatchError {
+	if actual := v.digester.Digest(); actual != v.expected {
+		return &DigestMismatchError{Expected: v.expected.String(), Actual: actual.String()}
+	}
+
+	return nil
+}
+
+// FetchChecksum downloads the checksum file at url in the format of sha256sum
+// and returns the digest of the entry for filename in the form '<algorithm>:<hex>'.
+func (f *Fetcher) FetchChecksum(ctx context.Context, url, filename string, opts ...FetchOptionsFn) (string, error) {
+	content, err := f.fetchSmall(ctx, url, opts...)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch checksum file: %w", err)
+	}
+
+	return findChecksum(bytes.NewReader(content), filename)
+}
+
+// fetchSmall downloads the content at url into memory, which is limited to maxVerificationFileSize.
+func (f *Fetcher) fetchSmall(ctx context.Context, url string, opts ...FetchOptionsFn) (_ []byte, retErr error) {
+	opt := &FetchOptions{}
+	for _, fn := range opts {
+		fn(opt)
+	}
+
+	ctx, cancel := opt.withTimeout(ctx)
+	defer cancel()
+	defer func() {
+		retErr = opt.timeoutError(ctx, retErr)
+	}()
+
+	req, err := f.newRequest(ctx, url, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.doWithRetry(req, opt)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, statusError(resp)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxVerificationFileSize))
+}
+
+// findChecksum looks up the checksum of filename in lines of the form
+// '<hex>  <filename>', where the file name may be prefixed by '*' for binary mode.
+func findChecksum(r io.Reader, filename string) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		name := strings.TrimPrefix(strings.TrimPrefix(fields[1], "*"), "./")
+		if name != filename {
+			continue
+		}
+
+		algorithm, ok := checksumAlgorithms[len(fields[0])]
+		if !ok {
+			return "", fmt.Errorf("unsupported checksum '%s' for '%s'", fields[0], filename)
+		}
+
+		d := digest.NewDigestFromEncoded(algorithm, strings.ToLower(fields[0]))
+		if err := d.Validate(); err != nil {
+			return "", fmt.Errorf("invalid checksum for '%s': %w", filename, err)
+		}
+
+		return d.String(), nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read checksum file: %w", err)
+	}
+
+	return "", fmt.Errorf("no checksum found for '%s'", filename)
+}
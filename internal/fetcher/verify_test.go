@@ -0,0 +1,68 @@
+package fetcher
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindChecksum(t *testing.T) {
+	sha256sum := strings.Repeat("a", 64)
+	sha512sum := strings.Repeat("b", 128)
+
+	tests := []struct {
+		name      string
+		content   string
+		filename  string
+		want      string
+		assertErr func(t *testing.T, err error)
+	}{
+		{
+			name:     "text mode",
+			content:  sha256sum + "  content.tar.gz\n",
+			filename: "content.tar.gz",
+			want:     "sha256:" + sha256sum,
+		},
+		{
+			name:     "binary mode",
+			content:  sha512sum + " *content.tar.gz\n",
+			filename: "content.tar.gz",
+			want:     "sha512:" + sha512sum,
+		},
+		{
+			name:     "relative path",
+			content:  strings.Repeat("c", 64) + "  other.tar.gz\n" + sha256sum + "  ./content.tar.gz\n",
+			filename: "content.tar.gz",
+			want:     "sha256:" + sha256sum,
+		},
+		{
+			name:     "missing entry",
+			content:  sha256sum + "  other.tar.gz\n",
+			filename: "content.tar.gz",
+			assertErr: func(t *testing.T, err error) {
+				require.ErrorContains(t, err, "no checksum found for 'content.tar.gz'")
+			},
+		},
+		{
+			name:     "unsupported checksum",
+			content:  "abc  content.tar.gz\n",
+			filename: "content.tar.gz",
+			assertErr: func(t *testing.T, err error) {
+				require.ErrorContains(t, err, "unsupported checksum")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findChecksum(strings.NewReader(tt.content), tt.filename)
+			if tt.assertErr != nil {
+				tt.assertErr(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
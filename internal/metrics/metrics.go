@@ -0,0 +1,97 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics provides the Prometheus collectors of the controller, which
+// are registered on the metrics registry of controller-runtime.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "http_source"
+
+var (
+	// DownloadDuration observes the duration of content downloads per host.
+	DownloadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "download_duration_seconds",
+		Help:      "Duration of content downloads in seconds.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+	}, []string{"host"})
+
+	// DownloadBytes observes the size of downloaded content per host.
+	DownloadBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "download_bytes",
+		Help:      "Size of downloaded content in bytes.",
+		Buckets:   prometheus.ExponentialBuckets(1024, 4, 12),
+	}, []string{"host"})
+
+	// Responses counts HTTP responses per host and status code.
+	Responses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_responses_total",
+		Help:      "Number of HTTP responses by host and status code.",
+	}, []string{"host", "code"})
+
+	// ExtractionDuration observes the duration of archive extractions per format.
+	ExtractionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "extraction_duration_seconds",
+		Help:      "Duration of archive extractions in seconds.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"format"})
+
+	// ArtifactSize observes the size of published Artifacts.
+	ArtifactSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "artifact_size_bytes",
+		Help:      "Size of published Artifacts in bytes.",
+		Buckets:   prometheus.ExponentialBuckets(1024, 4, 12),
+	})
+
+	// RevisionTimestamp is the time the currently published revision of an Http object was stored.
+	RevisionTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "artifact_revision_timestamp_seconds",
+		Help:      "Unix time the currently published revision of an Http object was stored.",
+	}, []string{"namespace", "name"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		DownloadDuration,
+		DownloadBytes,
+		Responses,
+		ExtractionDuration,
+		ArtifactSize,
+		RevisionTimestamp,
+	)
+}
+
+// SetRevisionTimestamp records the time the published revision of the object was stored.
+func SetRevisionTimestamp(objNamespace, name string, t time.Time) {
+	RevisionTimestamp.WithLabelValues(objNamespace, name).Set(float64(t.Unix()))
+}
+
+// DeleteObject removes the series of the deleted object.
+func DeleteObject(objNamespace, name string) {
+	RevisionTimestamp.DeleteLabelValues(objNamespace, name)
+}